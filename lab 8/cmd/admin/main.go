@@ -3,8 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
 	"time"
 	"tritontube/internal/proto"
 
@@ -30,11 +35,20 @@ func main() {
 
 	switch cmd {
 	case "add":
-		if len(os.Args) != 4 {
-			fmt.Println("Usage: add <server_address> <node_address>")
+		if len(os.Args) != 4 && len(os.Args) != 5 {
+			fmt.Println("Usage: add <server_address> <node_address> [weight]")
 			os.Exit(1)
 		}
-		addNode(client, os.Args[3])
+		weight := 1
+		if len(os.Args) == 5 {
+			w, err := strconv.Atoi(os.Args[4])
+			if err != nil || w <= 0 {
+				fmt.Printf("Invalid weight: %s\n", os.Args[4])
+				os.Exit(1)
+			}
+			weight = w
+		}
+		addNode(client, os.Args[3], weight)
 	case "remove":
 		if len(os.Args) != 4 {
 			fmt.Println("Usage: remove <server_address> <node_address>")
@@ -47,6 +61,35 @@ func main() {
 			os.Exit(1)
 		}
 		listNodes(client)
+	case "watch":
+		if len(os.Args) != 3 && len(os.Args) != 4 {
+			fmt.Println("Usage: watch <server_address> [node_address]")
+			os.Exit(1)
+		}
+		nodeAddr := ""
+		if len(os.Args) == 4 {
+			nodeAddr = os.Args[3]
+		}
+		progress := watchMigration(client, nodeAddr)
+		exitOnMigrationFailure(progress)
+		fmt.Printf("Migration of %s finished: %d files migrated, %d failed\n",
+			progress.NodeAddress, progress.KeysMoved, progress.KeysFailed)
+	case "stats":
+		if len(os.Args) != 3 && len(os.Args) != 4 {
+			fmt.Println("Usage: stats <server_address> [node_address]")
+			os.Exit(1)
+		}
+		nodeAddr := ""
+		if len(os.Args) == 4 {
+			nodeAddr = os.Args[3]
+		}
+		showStats(client, nodeAddr)
+	case "verify":
+		if len(os.Args) != 4 {
+			fmt.Println("Usage: verify <server_address> <node_address>")
+			os.Exit(1)
+		}
+		verifyNode(client, os.Args[3])
 	default:
 		fmt.Printf("Unknown command: %s\n", cmd)
 		printUsageAndExit()
@@ -55,40 +98,115 @@ func main() {
 
 func printUsageAndExit() {
 	fmt.Println("Usage:")
-	fmt.Println("  add <server_address> <node_address>     - Add a node to the cluster")
-	fmt.Println("  remove <server_address> <node_address>  - Remove a node from the cluster")
-	fmt.Println("  list <server_address>                   - List all nodes in the cluster")
+	fmt.Println("  add <server_address> <node_address> [weight]  - Add a node to the cluster")
+	fmt.Println("  remove <server_address> <node_address>        - Remove a node from the cluster")
+	fmt.Println("  list <server_address>                         - List all nodes in the cluster")
+	fmt.Println("  watch <server_address> [node_address]         - Reattach to a running migration")
+	fmt.Println("  stats <server_address> [node_address]         - Show storage usage and request counts")
+	fmt.Println("  verify <server_address> <node_address>        - Check the checksums of all files on a node")
 	os.Exit(1)
 }
 
-func addNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func addNode(client proto.VideoContentAdminServiceClient, nodeAddr string, weight int) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
-	response, err := client.AddNode(ctx, &proto.AddNodeRequest{
+	_, err := client.AddNode(ctx, &proto.AddNodeRequest{
 		NodeAddress: nodeAddr,
+		Weight:      int32(weight),
+		Background:  true,
 	})
 	if err != nil {
 		log.Fatalf("AddNode RPC failed: %v", err)
 	}
 
+	progress := watchMigration(client, nodeAddr)
+	exitOnMigrationFailure(progress)
 	fmt.Printf("Successfully added node: %s\n", nodeAddr)
-	fmt.Printf("Number of files migrated: %d\n", response.MigratedFileCount)
+	fmt.Printf("Number of files migrated: %d\n", progress.KeysMoved)
 }
 
 func removeNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
-	response, err := client.RemoveNode(ctx, &proto.RemoveNodeRequest{
+	_, err := client.RemoveNode(ctx, &proto.RemoveNodeRequest{
 		NodeAddress: nodeAddr,
+		Background:  true,
 	})
 	if err != nil {
 		log.Fatalf("RemoveNode RPC failed: %v", err)
 	}
 
+	progress := watchMigration(client, nodeAddr)
+	exitOnMigrationFailure(progress)
 	fmt.Printf("Successfully removed node: %s\n", nodeAddr)
-	fmt.Printf("Number of files migrated: %d\n", response.MigratedFileCount)
+	fmt.Printf("Number of files migrated: %d\n", progress.KeysMoved)
+}
+
+// watchMigration renders the progress of the migration of nodeAddr (or the
+// running one if empty) on a single line until it finishes, and returns the
+// final progress. The migration keeps running if the watch is interrupted.
+func watchMigration(client proto.VideoContentAdminServiceClient, nodeAddr string) *proto.MigrationProgress {
+	stream, err := client.WatchMigration(context.Background(), &proto.WatchMigrationRequest{
+		NodeAddress: nodeAddr,
+	})
+	if err != nil {
+		log.Fatalf("WatchMigration RPC failed: %v", err)
+	}
+
+	var last *proto.MigrationProgress
+	for {
+		progress, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println()
+			log.Fatalf("WatchMigration RPC failed: %v", err)
+		}
+		last = progress
+
+		eta := "--"
+		if progress.EtaSeconds > 0 {
+			eta = (time.Duration(progress.EtaSeconds) * time.Second).String()
+		}
+		fmt.Printf("\r\033[K%s %s: %d/%d keys, %s moved, %d failed, ETA %s",
+			progress.State, progress.NodeAddress, progress.KeysMoved, progress.KeysPlanned,
+			formatBytes(progress.BytesMoved), progress.KeysFailed, eta)
+	}
+	fmt.Println()
+
+	if last == nil {
+		log.Fatalf("WatchMigration RPC returned no progress")
+	}
+	return last
+}
+
+// exitOnMigrationFailure reports an aborted migration and the keys that could
+// not be migrated, then exits.
+func exitOnMigrationFailure(progress *proto.MigrationProgress) {
+	if progress.Error == "" {
+		return
+	}
+	fmt.Printf("Migration failed: %s\n", progress.Error)
+	for _, key := range progress.FailedKeys {
+		fmt.Printf("  - %s\n", key)
+	}
+	os.Exit(1)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
 func listNodes(client proto.VideoContentAdminServiceClient) {
@@ -101,11 +219,112 @@ func listNodes(client proto.VideoContentAdminServiceClient) {
 	}
 
 	fmt.Println("Storage cluster nodes:")
-	if len(response.Nodes) == 0 {
+	if len(response.NodeInfos) == 0 {
 		fmt.Println("  No nodes in cluster")
 	} else {
-		for _, node := range response.Nodes {
-			fmt.Printf("  - %s\n", node)
+		for _, node := range response.NodeInfos {
+			lastSeen := "never"
+			if node.LastSeen != nil {
+				lastSeen = time.Since(node.LastSeen.AsTime()).Truncate(time.Second).String() + " ago"
+			}
+			fmt.Printf("  - %s (%s, %s, weight %d, last seen %s)\n", node.Address, node.State, node.Health, node.Weight, lastSeen)
+		}
+	}
+}
+
+func verifyNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	response, err := client.VerifyNode(ctx, &proto.VerifyNodeRequest{
+		NodeAddress: nodeAddr,
+	})
+	if err != nil {
+		log.Fatalf("VerifyNode RPC failed: %v", err)
+	}
+
+	fmt.Printf("Verified %d files on node: %s\n", response.CheckedCount, nodeAddr)
+	if len(response.UnverifiedKeys) > 0 {
+		fmt.Printf("Files without a checksum: %d\n", len(response.UnverifiedKeys))
+	}
+	if len(response.CorruptKeys) == 0 {
+		fmt.Println("No corrupt files found")
+		return
+	}
+	fmt.Printf("Corrupt files: %d\n", len(response.CorruptKeys))
+	for _, key := range response.CorruptKeys {
+		fmt.Printf("  - %s\n", key)
+	}
+	os.Exit(1)
+}
+
+// showStats queries every storage node in the cluster, or only nodeAddr if
+// set, and prints their usage followed by the totals.
+func showStats(client proto.VideoContentAdminServiceClient, nodeAddr string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	response, err := client.ListNodes(ctx, &proto.ListNodesRequest{})
+	if err != nil {
+		log.Fatalf("ListNodes RPC failed: %v", err)
+	}
+	nodes := response.Nodes
+	if nodeAddr != "" {
+		if !slices.Contains(nodes, nodeAddr) {
+			log.Fatalf("Node %s is not in the cluster", nodeAddr)
+		}
+		nodes = []string{nodeAddr}
+	}
+
+	var total proto.GetStatsResponse
+	total.RequestCounts = make(map[string]int64)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tFILES\tUSED\tFREE\tREQUESTS\tUPTIME")
+	for _, node := range nodes {
+		stats, err := getNodeStats(ctx, node)
+		if err != nil {
+			fmt.Fprintf(w, "%s\terror: %v\n", node, err)
+			continue
+		}
+
+		var requests int64
+		for method, n := range stats.RequestCounts {
+			requests += n
+			total.RequestCounts[method] += n
+		}
+		free := "unknown"
+		if stats.FreeBytes >= 0 {
+			free = formatBytes(stats.FreeBytes)
+			total.FreeBytes += stats.FreeBytes
+		}
+		total.FileCount += stats.FileCount
+		total.TotalBytes += stats.TotalBytes
+
+		uptime := time.Since(stats.StartedAt.AsTime()).Truncate(time.Second)
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\n", node, stats.FileCount,
+			formatBytes(stats.TotalBytes), free, requests, uptime)
+	}
+	w.Flush()
+
+	if len(nodes) > 1 {
+		fmt.Printf("\nTotal: %d files, %s used, %s free\n", total.FileCount,
+			formatBytes(total.TotalBytes), formatBytes(total.FreeBytes))
+	}
+	methods := slices.Sorted(maps.Keys(total.RequestCounts))
+	if len(methods) > 0 {
+		fmt.Println("Requests since start:")
+		for _, method := range methods {
+			fmt.Printf("  %-12s %d\n", method, total.RequestCounts[method])
 		}
 	}
 }
+
+func getNodeStats(ctx context.Context, nodeAddr string) (*proto.GetStatsResponse, error) {
+	conn, err := grpc.NewClient(nodeAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return proto.NewVideoContentStorageServiceClient(conn).GetStats(ctx, &proto.GetStatsRequest{})
+}
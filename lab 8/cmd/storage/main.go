@@ -9,6 +9,8 @@ import (
 	"tritontube/internal/storage"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
@@ -42,6 +44,7 @@ func main() {
 	grpcServer := grpc.NewServer()
 	storageServer := storage.NewStorageServer(baseDir)
 	proto.RegisterVideoContentStorageServiceServer(grpcServer, storageServer)
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve gRPC server: %v", err)
@@ -7,7 +7,7 @@ import (
 	"log"
 	"net"
 	"strings"
-	"tritontube/internal/proto"
+	"time"
 	"tritontube/internal/web"
 )
 
@@ -20,17 +20,32 @@ func printUsage() {
 	fmt.Println("  METADATA_OPTIONS      Options for metadata service (e.g., db path)")
 	fmt.Println("  CONTENT_TYPE          Content service type (fs, nw)")
 	fmt.Println("  CONTENT_OPTIONS       Options for content service (e.g., base dir, network addresses)")
+	fmt.Println("                        For nw: admin address, then node addresses, each optionally")
+	fmt.Println("                        followed by =weight as logged after adding or removing a node")
 	fmt.Println()
 	fmt.Println("Options:")
 	flag.PrintDefaults()
 	fmt.Println()
 	fmt.Println("Example: ./program sqlite db.db fs /path/to/videos")
+	fmt.Println("         ./program etcd localhost:2379,localhost:22379 fs /path/to/videos")
 }
 
 func main() {
 	// Define flags
 	port := flag.Int("port", 8080, "Port number for the web server")
 	host := flag.String("host", "localhost", "Host address for the web server")
+	replicas := flag.Int("replicas", 1, "Number of storage nodes each file is replicated to (nw only)")
+	vnodes := flag.Int("vnodes", 64, "Number of virtual nodes per storage node on the hash ring (nw only)")
+	healthInterval := flag.Duration("health-interval", 5*time.Second, "Interval between storage node health probes (nw only)")
+	healthFailures := flag.Int("health-failures", 3, "Consecutive failed probes before a storage node is marked down (nw only)")
+	healthRecoveries := flag.Int("health-recoveries", 2, "Consecutive successful probes before a down storage node is marked up (nw only)")
+	transcodeWorkers := flag.Int("transcode-workers", 2, "Number of uploads transcoded concurrently")
+	transcodeQueue := flag.Int("transcode-queue", 16, "Number of uploads that may wait for a transcoding worker")
+	posterOffset := flag.Duration("poster-offset", 5*time.Second, "Position in each uploaded video to take its poster image from")
+	uploadDir := flag.String("upload-dir", "", "Staging directory for resumable uploads (default a directory under the system temp dir)")
+	cacheMB := flag.Int64("cache-mb", 0, "Megabytes of video content to cache in memory, 0 to disable")
+	cacheFileMB := flag.Int64("cache-max-file-mb", 8, "Megabytes of the largest file cached; larger files are always read from the content service")
+	ladderSpec := flag.String("ladder", "240:400k,480:1000k,720:3000k,1080:6000k", "Comma-separated height:bitrate renditions to transcode uploads to")
 
 	// Set custom usage message
 	flag.Usage = printUsage
@@ -58,6 +73,54 @@ func main() {
 		return
 	}
 
+	if *replicas <= 0 {
+		fmt.Println("Error: Invalid replication factor:", *replicas)
+		printUsage()
+		return
+	}
+
+	if *vnodes <= 0 {
+		fmt.Println("Error: Invalid number of virtual nodes:", *vnodes)
+		printUsage()
+		return
+	}
+
+	if *transcodeWorkers <= 0 || *transcodeQueue <= 0 {
+		fmt.Println("Error: Transcoding workers and queue size must be positive")
+		printUsage()
+		return
+	}
+
+	if *posterOffset < 0 {
+		fmt.Println("Error: Invalid poster offset:", *posterOffset)
+		printUsage()
+		return
+	}
+
+	ladder, err := web.ParseLadder(*ladderSpec)
+	if err != nil {
+		fmt.Println("Error: Invalid ladder:", err)
+		printUsage()
+		return
+	}
+
+	if *cacheMB < 0 {
+		fmt.Println("Error: Invalid cache size:", *cacheMB)
+		printUsage()
+		return
+	}
+	if *cacheFileMB <= 0 {
+		fmt.Println("Error: Invalid cache file size:", *cacheFileMB)
+		printUsage()
+		return
+	}
+
+	if *healthInterval <= 0 || *healthFailures <= 0 || *healthRecoveries <= 0 {
+		fmt.Println("Error: Health check interval and thresholds must be positive")
+		printUsage()
+		return
+	}
+
 	// Construct metadata service
 	var metadataService web.VideoMetadataService
 	fmt.Println("Creating metadata service of type", metadataServiceType, "with options", metadataServiceOptions)
@@ -70,6 +133,14 @@ func main() {
 			return
 		}
 		metadataService = sqliteMetadataService
+	case "etcd":
+		etcdMetadataService, err := web.NewEtcdVideoMetadataService(strings.Split(metadataServiceOptions, ","))
+		if err != nil {
+			fmt.Printf("Failed to start etcd metadata service: %v\n", err)
+			return
+		}
+		defer etcdMetadataService.Close()
+		metadataService = etcdMetadataService
 	default:
 		fmt.Println("Unsupported metadata service type: ", metadataServiceType)
 		return
@@ -90,18 +161,22 @@ func main() {
 	case "nw":
 		nodes := strings.Split(contentServiceOptions, ",")
 		adminNode := nodes[0]
-		nwContentService := web.NewNetworkVideoContentService()
+		nwContentService := web.NewNetworkVideoContentService(*replicas, *vnodes)
+
+		// The nodes may already hold files placed for the ring of all of
+		// them, so they join together instead of migrating one by one.
+		if err := nwContentService.InitNodes(nodes[1:]); err != nil {
+			log.Fatalf("Failed to add nodes: %v", err)
+		}
+		if err := nwContentService.RebuildIndex(context.Background()); err != nil {
+			log.Fatalf("Failed to rebuild key index: %v", err)
+		}
 		go func() {
 			if err := nwContentService.StartAdminServer(adminNode); err != nil {
-				log.Fatalf("Failed to start dmin server: %v", err)
+				log.Fatalf("Failed to start admin server: %v", err)
 			}
 		}()
-
-		for _, node := range nodes[1:] {
-			if _, err := nwContentService.AddNode(context.Background(), &proto.AddNodeRequest{NodeAddress: node}); err != nil {
-				log.Fatalf("Failed to add node %v: %v", node, err)
-			}
-		}
+		nwContentService.StartHealthChecker(context.Background(), *healthInterval, *healthFailures, *healthRecoveries)
 
 		contentService = nwContentService
 	default:
@@ -109,8 +184,18 @@ func main() {
 		return
 	}
 
+	if *cacheMB > 0 {
+		contentService = web.NewCachingVideoContentService(contentService, *cacheMB<<20, *cacheFileMB<<20)
+	}
+
 	// Start the server
-	server := web.NewServer(metadataService, contentService)
+	server := web.NewServer(metadataService, contentService, web.ServerOptions{
+		TranscodeWorkers:   *transcodeWorkers,
+		TranscodeQueueSize: *transcodeQueue,
+		Ladder:             ladder,
+		PosterOffset:       *posterOffset,
+		UploadDir:          *uploadDir,
+	})
 	listenAddr := fmt.Sprintf("%s:%d", *host, *port)
 	lis, err := net.Listen("tcp", listenAddr)
 	if err != nil {
@@ -9,6 +9,7 @@ package proto
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -22,8 +23,12 @@ const (
 )
 
 type AddNodeRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	NodeAddress string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	// Relative share of the key space; 0 is treated as 1.
+	Weight int32 `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	// Return once the migration has started instead of when it finishes.
+	Background    bool `protobuf:"varint,3,opt,name=background,proto3" json:"background,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,6 +70,20 @@ func (x *AddNodeRequest) GetNodeAddress() string {
 	return ""
 }
 
+func (x *AddNodeRequest) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *AddNodeRequest) GetBackground() bool {
+	if x != nil {
+		return x.Background
+	}
+	return false
+}
+
 type AddNodeResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"`
@@ -110,8 +129,10 @@ func (x *AddNodeResponse) GetMigratedFileCount() int32 {
 }
 
 type RemoveNodeRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	NodeAddress string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	// Return once the migration has started instead of when it finishes.
+	Background    bool `protobuf:"varint,2,opt,name=background,proto3" json:"background,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -153,6 +174,13 @@ func (x *RemoveNodeRequest) GetNodeAddress() string {
 	return ""
 }
 
+func (x *RemoveNodeRequest) GetBackground() bool {
+	if x != nil {
+		return x.Background
+	}
+	return false
+}
+
 type RemoveNodeResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"`
@@ -236,6 +264,7 @@ func (*ListNodesRequest) Descriptor() ([]byte, []int) {
 type ListNodesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Nodes         []string               `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	NodeInfos     []*NodeInfo            `protobuf:"bytes,2,rep,name=node_infos,json=nodeInfos,proto3" json:"node_infos,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -277,28 +306,427 @@ func (x *ListNodesResponse) GetNodes() []string {
 	return nil
 }
 
+func (x *ListNodesResponse) GetNodeInfos() []*NodeInfo {
+	if x != nil {
+		return x.NodeInfos
+	}
+	return nil
+}
+
+type NodeInfo struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Address string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	// "active", or "joining"/"leaving" while the node's keys are migrated.
+	State string `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	// "up", "suspect" or "down" according to the periodic health probes.
+	Health string `protobuf:"bytes,3,opt,name=health,proto3" json:"health,omitempty"`
+	// Time of the last successful health probe, unset if none succeeded yet.
+	LastSeen *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	// Weight of the node on the hash ring, or 0 while it is joining.
+	Weight        int32 `protobuf:"varint,5,opt,name=weight,proto3" json:"weight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeInfo) Reset() {
+	*x = NodeInfo{}
+	mi := &file_proto_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeInfo) ProtoMessage() {}
+
+func (x *NodeInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeInfo.ProtoReflect.Descriptor instead.
+func (*NodeInfo) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NodeInfo) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetHealth() string {
+	if x != nil {
+		return x.Health
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+func (x *NodeInfo) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+type VerifyNodeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyNodeRequest) Reset() {
+	*x = VerifyNodeRequest{}
+	mi := &file_proto_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyNodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyNodeRequest) ProtoMessage() {}
+
+func (x *VerifyNodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyNodeRequest.ProtoReflect.Descriptor instead.
+func (*VerifyNodeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *VerifyNodeRequest) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+type VerifyNodeResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	CheckedCount   int32                  `protobuf:"varint,1,opt,name=checked_count,json=checkedCount,proto3" json:"checked_count,omitempty"`
+	CorruptKeys    []string               `protobuf:"bytes,2,rep,name=corrupt_keys,json=corruptKeys,proto3" json:"corrupt_keys,omitempty"`
+	UnverifiedKeys []string               `protobuf:"bytes,3,rep,name=unverified_keys,json=unverifiedKeys,proto3" json:"unverified_keys,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *VerifyNodeResponse) Reset() {
+	*x = VerifyNodeResponse{}
+	mi := &file_proto_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyNodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyNodeResponse) ProtoMessage() {}
+
+func (x *VerifyNodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyNodeResponse.ProtoReflect.Descriptor instead.
+func (*VerifyNodeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *VerifyNodeResponse) GetCheckedCount() int32 {
+	if x != nil {
+		return x.CheckedCount
+	}
+	return 0
+}
+
+func (x *VerifyNodeResponse) GetCorruptKeys() []string {
+	if x != nil {
+		return x.CorruptKeys
+	}
+	return nil
+}
+
+func (x *VerifyNodeResponse) GetUnverifiedKeys() []string {
+	if x != nil {
+		return x.UnverifiedKeys
+	}
+	return nil
+}
+
+type WatchMigrationRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Node whose migration to watch; empty selects the running one.
+	NodeAddress   string `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchMigrationRequest) Reset() {
+	*x = WatchMigrationRequest{}
+	mi := &file_proto_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchMigrationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchMigrationRequest) ProtoMessage() {}
+
+func (x *WatchMigrationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchMigrationRequest.ProtoReflect.Descriptor instead.
+func (*WatchMigrationRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchMigrationRequest) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+type MigrationProgress struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	NodeAddress string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	// "joining" or "leaving".
+	State       string `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	KeysPlanned int32  `protobuf:"varint,3,opt,name=keys_planned,json=keysPlanned,proto3" json:"keys_planned,omitempty"`
+	KeysMoved   int32  `protobuf:"varint,4,opt,name=keys_moved,json=keysMoved,proto3" json:"keys_moved,omitempty"`
+	BytesMoved  int64  `protobuf:"varint,5,opt,name=bytes_moved,json=bytesMoved,proto3" json:"bytes_moved,omitempty"`
+	KeysFailed  int32  `protobuf:"varint,6,opt,name=keys_failed,json=keysFailed,proto3" json:"keys_failed,omitempty"`
+	// Estimated time until the migration finishes, 0 if unknown.
+	EtaSeconds float64 `protobuf:"fixed64,7,opt,name=eta_seconds,json=etaSeconds,proto3" json:"eta_seconds,omitempty"`
+	Done       bool    `protobuf:"varint,8,opt,name=done,proto3" json:"done,omitempty"`
+	// Set once done if the migration was aborted.
+	Error string `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	// Keys that could not be migrated, set once done.
+	FailedKeys    []string `protobuf:"bytes,10,rep,name=failed_keys,json=failedKeys,proto3" json:"failed_keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrationProgress) Reset() {
+	*x = MigrationProgress{}
+	mi := &file_proto_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrationProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrationProgress) ProtoMessage() {}
+
+func (x *MigrationProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrationProgress.ProtoReflect.Descriptor instead.
+func (*MigrationProgress) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MigrationProgress) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+func (x *MigrationProgress) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *MigrationProgress) GetKeysPlanned() int32 {
+	if x != nil {
+		return x.KeysPlanned
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetKeysMoved() int32 {
+	if x != nil {
+		return x.KeysMoved
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetBytesMoved() int64 {
+	if x != nil {
+		return x.BytesMoved
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetKeysFailed() int32 {
+	if x != nil {
+		return x.KeysFailed
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetEtaSeconds() float64 {
+	if x != nil {
+		return x.EtaSeconds
+	}
+	return 0
+}
+
+func (x *MigrationProgress) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *MigrationProgress) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *MigrationProgress) GetFailedKeys() []string {
+	if x != nil {
+		return x.FailedKeys
+	}
+	return nil
+}
+
 var File_proto_admin_proto protoreflect.FileDescriptor
 
 const file_proto_admin_proto_rawDesc = "" +
 	"\n" +
 	"\x11proto/admin.proto\x12\n" +
-	"tritontube\"3\n" +
+	"tritontube\x1a\x1fgoogle/protobuf/timestamp.proto\"k\n" +
 	"\x0eAddNodeRequest\x12!\n" +
-	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"A\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\x12\x1e\n" +
+	"\n" +
+	"background\x18\x03 \x01(\bR\n" +
+	"background\"A\n" +
 	"\x0fAddNodeResponse\x12.\n" +
-	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\"6\n" +
+	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\"V\n" +
 	"\x11RemoveNodeRequest\x12!\n" +
-	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"D\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x1e\n" +
+	"\n" +
+	"background\x18\x02 \x01(\bR\n" +
+	"background\"D\n" +
 	"\x12RemoveNodeResponse\x12.\n" +
 	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\"\x12\n" +
-	"\x10ListNodesRequest\")\n" +
+	"\x10ListNodesRequest\"^\n" +
 	"\x11ListNodesResponse\x12\x14\n" +
-	"\x05nodes\x18\x01 \x03(\tR\x05nodes2\xf5\x01\n" +
+	"\x05nodes\x18\x01 \x03(\tR\x05nodes\x123\n" +
+	"\n" +
+	"node_infos\x18\x02 \x03(\v2\x14.tritontube.NodeInfoR\tnodeInfos\"\xa3\x01\n" +
+	"\bNodeInfo\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x16\n" +
+	"\x06health\x18\x03 \x01(\tR\x06health\x127\n" +
+	"\tlast_seen\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\x12\x16\n" +
+	"\x06weight\x18\x05 \x01(\x05R\x06weight\"6\n" +
+	"\x11VerifyNodeRequest\x12!\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"\x85\x01\n" +
+	"\x12VerifyNodeResponse\x12#\n" +
+	"\rchecked_count\x18\x01 \x01(\x05R\fcheckedCount\x12!\n" +
+	"\fcorrupt_keys\x18\x02 \x03(\tR\vcorruptKeys\x12'\n" +
+	"\x0funverified_keys\x18\x03 \x03(\tR\x0eunverifiedKeys\":\n" +
+	"\x15WatchMigrationRequest\x12!\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"\xbc\x02\n" +
+	"\x11MigrationProgress\x12!\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12!\n" +
+	"\fkeys_planned\x18\x03 \x01(\x05R\vkeysPlanned\x12\x1d\n" +
+	"\n" +
+	"keys_moved\x18\x04 \x01(\x05R\tkeysMoved\x12\x1f\n" +
+	"\vbytes_moved\x18\x05 \x01(\x03R\n" +
+	"bytesMoved\x12\x1f\n" +
+	"\vkeys_failed\x18\x06 \x01(\x05R\n" +
+	"keysFailed\x12\x1f\n" +
+	"\veta_seconds\x18\a \x01(\x01R\n" +
+	"etaSeconds\x12\x12\n" +
+	"\x04done\x18\b \x01(\bR\x04done\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\x12\x1f\n" +
+	"\vfailed_keys\x18\n" +
+	" \x03(\tR\n" +
+	"failedKeys2\x98\x03\n" +
 	"\x18VideoContentAdminService\x12B\n" +
 	"\aAddNode\x12\x1a.tritontube.AddNodeRequest\x1a\x1b.tritontube.AddNodeResponse\x12K\n" +
 	"\n" +
 	"RemoveNode\x12\x1d.tritontube.RemoveNodeRequest\x1a\x1e.tritontube.RemoveNodeResponse\x12H\n" +
-	"\tListNodes\x12\x1c.tritontube.ListNodesRequest\x1a\x1d.tritontube.ListNodesResponseB\x16Z\x14internal/proto;protob\x06proto3"
+	"\tListNodes\x12\x1c.tritontube.ListNodesRequest\x1a\x1d.tritontube.ListNodesResponse\x12K\n" +
+	"\n" +
+	"VerifyNode\x12\x1d.tritontube.VerifyNodeRequest\x1a\x1e.tritontube.VerifyNodeResponse\x12T\n" +
+	"\x0eWatchMigration\x12!.tritontube.WatchMigrationRequest\x1a\x1d.tritontube.MigrationProgress0\x01B\x16Z\x14internal/proto;protob\x06proto3"
 
 var (
 	file_proto_admin_proto_rawDescOnce sync.Once
@@ -312,27 +740,39 @@ func file_proto_admin_proto_rawDescGZIP() []byte {
 	return file_proto_admin_proto_rawDescData
 }
 
-var file_proto_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_proto_admin_proto_goTypes = []any{
-	(*AddNodeRequest)(nil),     // 0: tritontube.AddNodeRequest
-	(*AddNodeResponse)(nil),    // 1: tritontube.AddNodeResponse
-	(*RemoveNodeRequest)(nil),  // 2: tritontube.RemoveNodeRequest
-	(*RemoveNodeResponse)(nil), // 3: tritontube.RemoveNodeResponse
-	(*ListNodesRequest)(nil),   // 4: tritontube.ListNodesRequest
-	(*ListNodesResponse)(nil),  // 5: tritontube.ListNodesResponse
+	(*AddNodeRequest)(nil),        // 0: tritontube.AddNodeRequest
+	(*AddNodeResponse)(nil),       // 1: tritontube.AddNodeResponse
+	(*RemoveNodeRequest)(nil),     // 2: tritontube.RemoveNodeRequest
+	(*RemoveNodeResponse)(nil),    // 3: tritontube.RemoveNodeResponse
+	(*ListNodesRequest)(nil),      // 4: tritontube.ListNodesRequest
+	(*ListNodesResponse)(nil),     // 5: tritontube.ListNodesResponse
+	(*NodeInfo)(nil),              // 6: tritontube.NodeInfo
+	(*VerifyNodeRequest)(nil),     // 7: tritontube.VerifyNodeRequest
+	(*VerifyNodeResponse)(nil),    // 8: tritontube.VerifyNodeResponse
+	(*WatchMigrationRequest)(nil), // 9: tritontube.WatchMigrationRequest
+	(*MigrationProgress)(nil),     // 10: tritontube.MigrationProgress
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
 }
 var file_proto_admin_proto_depIdxs = []int32{
-	0, // 0: tritontube.VideoContentAdminService.AddNode:input_type -> tritontube.AddNodeRequest
-	2, // 1: tritontube.VideoContentAdminService.RemoveNode:input_type -> tritontube.RemoveNodeRequest
-	4, // 2: tritontube.VideoContentAdminService.ListNodes:input_type -> tritontube.ListNodesRequest
-	1, // 3: tritontube.VideoContentAdminService.AddNode:output_type -> tritontube.AddNodeResponse
-	3, // 4: tritontube.VideoContentAdminService.RemoveNode:output_type -> tritontube.RemoveNodeResponse
-	5, // 5: tritontube.VideoContentAdminService.ListNodes:output_type -> tritontube.ListNodesResponse
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	6,  // 0: tritontube.ListNodesResponse.node_infos:type_name -> tritontube.NodeInfo
+	11, // 1: tritontube.NodeInfo.last_seen:type_name -> google.protobuf.Timestamp
+	0,  // 2: tritontube.VideoContentAdminService.AddNode:input_type -> tritontube.AddNodeRequest
+	2,  // 3: tritontube.VideoContentAdminService.RemoveNode:input_type -> tritontube.RemoveNodeRequest
+	4,  // 4: tritontube.VideoContentAdminService.ListNodes:input_type -> tritontube.ListNodesRequest
+	7,  // 5: tritontube.VideoContentAdminService.VerifyNode:input_type -> tritontube.VerifyNodeRequest
+	9,  // 6: tritontube.VideoContentAdminService.WatchMigration:input_type -> tritontube.WatchMigrationRequest
+	1,  // 7: tritontube.VideoContentAdminService.AddNode:output_type -> tritontube.AddNodeResponse
+	3,  // 8: tritontube.VideoContentAdminService.RemoveNode:output_type -> tritontube.RemoveNodeResponse
+	5,  // 9: tritontube.VideoContentAdminService.ListNodes:output_type -> tritontube.ListNodesResponse
+	8,  // 10: tritontube.VideoContentAdminService.VerifyNode:output_type -> tritontube.VerifyNodeResponse
+	10, // 11: tritontube.VideoContentAdminService.WatchMigration:output_type -> tritontube.MigrationProgress
+	7,  // [7:12] is the sub-list for method output_type
+	2,  // [2:7] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_proto_admin_proto_init() }
@@ -346,7 +786,7 @@ func file_proto_admin_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_admin_proto_rawDesc), len(file_proto_admin_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -9,6 +9,7 @@ package proto
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,10 +22,15 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// StoreFileStream streams a file in chunks. The key only needs to be set on
+// the first message; every message carries the next chunk of data.
 type StoreFileRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Data  []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// SHA-256 of the whole file, sent on the last message. The server rejects
+	// the file if the received data does not match.
+	Sha256        []byte `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,6 +79,13 @@ func (x *StoreFileRequest) GetData() []byte {
 	return nil
 }
 
+func (x *StoreFileRequest) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
 type StoreFileResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -161,9 +174,15 @@ func (x *GetFileRequest) GetKey() string {
 	return ""
 }
 
+// GetFileStream streams the file back as a sequence of chunks.
 type GetFileResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Data  []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// SHA-256 of the whole file, sent on the first message. Empty for files
+	// stored without a checksum.
+	Sha256 []byte `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Size of the whole file in bytes, sent on the first message.
+	Size          int64 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -205,6 +224,20 @@ func (x *GetFileResponse) GetData() []byte {
 	return nil
 }
 
+func (x *GetFileResponse) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+func (x *GetFileResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
 type DeleteFileRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
@@ -293,30 +326,405 @@ func (x *DeleteFileResponse) GetSuccess() bool {
 	return false
 }
 
+// ListKeys returns the stored keys in lexical order, one page at a time.
+type ListKeysRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only keys starting with prefix are returned.
+	Prefix string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// Maximum number of keys to return; 0 selects the server default.
+	PageSize int32 `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token from the previous response, empty for the first page.
+	PageToken     string `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListKeysRequest) Reset() {
+	*x = ListKeysRequest{}
+	mi := &file_proto_storage_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListKeysRequest) ProtoMessage() {}
+
+func (x *ListKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListKeysRequest.ProtoReflect.Descriptor instead.
+func (*ListKeysRequest) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListKeysRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *ListKeysRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListKeysRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListKeysResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Keys  []string               `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	// Empty when there are no more keys.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListKeysResponse) Reset() {
+	*x = ListKeysResponse{}
+	mi := &file_proto_storage_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListKeysResponse) ProtoMessage() {}
+
+func (x *ListKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListKeysResponse.ProtoReflect.Descriptor instead.
+func (*ListKeysResponse) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListKeysResponse) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *ListKeysResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+// VerifyFiles recomputes the checksum of every stored file.
+type VerifyFilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prefix        string                 `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyFilesRequest) Reset() {
+	*x = VerifyFilesRequest{}
+	mi := &file_proto_storage_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyFilesRequest) ProtoMessage() {}
+
+func (x *VerifyFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyFilesRequest.ProtoReflect.Descriptor instead.
+func (*VerifyFilesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *VerifyFilesRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type VerifyFilesResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	CheckedCount int32                  `protobuf:"varint,1,opt,name=checked_count,json=checkedCount,proto3" json:"checked_count,omitempty"`
+	// Keys whose data no longer matches the stored checksum.
+	CorruptKeys []string `protobuf:"bytes,2,rep,name=corrupt_keys,json=corruptKeys,proto3" json:"corrupt_keys,omitempty"`
+	// Keys stored without a checksum.
+	UnverifiedKeys []string `protobuf:"bytes,3,rep,name=unverified_keys,json=unverifiedKeys,proto3" json:"unverified_keys,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *VerifyFilesResponse) Reset() {
+	*x = VerifyFilesResponse{}
+	mi := &file_proto_storage_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyFilesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyFilesResponse) ProtoMessage() {}
+
+func (x *VerifyFilesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyFilesResponse.ProtoReflect.Descriptor instead.
+func (*VerifyFilesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *VerifyFilesResponse) GetCheckedCount() int32 {
+	if x != nil {
+		return x.CheckedCount
+	}
+	return 0
+}
+
+func (x *VerifyFilesResponse) GetCorruptKeys() []string {
+	if x != nil {
+		return x.CorruptKeys
+	}
+	return nil
+}
+
+func (x *VerifyFilesResponse) GetUnverifiedKeys() []string {
+	if x != nil {
+		return x.UnverifiedKeys
+	}
+	return nil
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_proto_storage_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{10}
+}
+
+type GetStatsResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	FileCount  int64                  `protobuf:"varint,1,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"`
+	TotalBytes int64                  `protobuf:"varint,2,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	// Space available on the file system holding the base directory, or -1 if
+	// it cannot be determined.
+	FreeBytes int64 `protobuf:"varint,3,opt,name=free_bytes,json=freeBytes,proto3" json:"free_bytes,omitempty"`
+	// Number of requests served per RPC method since the server started.
+	RequestCounts map[string]int64       `protobuf:"bytes,4,rep,name=request_counts,json=requestCounts,proto3" json:"request_counts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	StartedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_proto_storage_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetStatsResponse) GetFileCount() int64 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetFreeBytes() int64 {
+	if x != nil {
+		return x.FreeBytes
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetRequestCounts() map[string]int64 {
+	if x != nil {
+		return x.RequestCounts
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
 var File_proto_storage_proto protoreflect.FileDescriptor
 
 const file_proto_storage_proto_rawDesc = "" +
 	"\n" +
 	"\x13proto/storage.proto\x12\n" +
-	"tritontube\"8\n" +
+	"tritontube\x1a\x1fgoogle/protobuf/timestamp.proto\"P\n" +
 	"\x10StoreFileRequest\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\fR\x04data\"-\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\fR\x06sha256\"-\n" +
 	"\x11StoreFileResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\"\"\n" +
 	"\x0eGetFileRequest\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\tR\x03key\"%\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"Q\n" +
 	"\x0fGetFileResponse\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\fR\x04data\"%\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x02 \x01(\fR\x06sha256\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\"%\n" +
 	"\x11DeleteFileRequest\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\".\n" +
 	"\x12DeleteFileResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2\xf7\x01\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"e\n" +
+	"\x0fListKeysRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"N\n" +
+	"\x10ListKeysResponse\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\tR\x04keys\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\",\n" +
+	"\x12VerifyFilesRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"\x86\x01\n" +
+	"\x13VerifyFilesResponse\x12#\n" +
+	"\rchecked_count\x18\x01 \x01(\x05R\fcheckedCount\x12!\n" +
+	"\fcorrupt_keys\x18\x02 \x03(\tR\vcorruptKeys\x12'\n" +
+	"\x0funverified_keys\x18\x03 \x03(\tR\x0eunverifiedKeys\"\x11\n" +
+	"\x0fGetStatsRequest\"\xc6\x02\n" +
+	"\x10GetStatsResponse\x12\x1d\n" +
+	"\n" +
+	"file_count\x18\x01 \x01(\x03R\tfileCount\x12\x1f\n" +
+	"\vtotal_bytes\x18\x02 \x01(\x03R\n" +
+	"totalBytes\x12\x1d\n" +
+	"\n" +
+	"free_bytes\x18\x03 \x01(\x03R\tfreeBytes\x12V\n" +
+	"\x0erequest_counts\x18\x04 \x03(\v2/.tritontube.GetStatsResponse.RequestCountsEntryR\rrequestCounts\x129\n" +
+	"\n" +
+	"started_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x1a@\n" +
+	"\x12RequestCountsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x012\xf3\x04\n" +
 	"\x1aVideoContentStorageService\x12H\n" +
 	"\tStoreFile\x12\x1c.tritontube.StoreFileRequest\x1a\x1d.tritontube.StoreFileResponse\x12B\n" +
-	"\aGetFile\x12\x1a.tritontube.GetFileRequest\x1a\x1b.tritontube.GetFileResponse\x12K\n" +
+	"\aGetFile\x12\x1a.tritontube.GetFileRequest\x1a\x1b.tritontube.GetFileResponse\x12P\n" +
+	"\x0fStoreFileStream\x12\x1c.tritontube.StoreFileRequest\x1a\x1d.tritontube.StoreFileResponse(\x01\x12J\n" +
+	"\rGetFileStream\x12\x1a.tritontube.GetFileRequest\x1a\x1b.tritontube.GetFileResponse0\x01\x12K\n" +
 	"\n" +
-	"DeleteFile\x12\x1d.tritontube.DeleteFileRequest\x1a\x1e.tritontube.DeleteFileResponseB\x16Z\x14internal/proto;protob\x06proto3"
+	"DeleteFile\x12\x1d.tritontube.DeleteFileRequest\x1a\x1e.tritontube.DeleteFileResponse\x12E\n" +
+	"\bListKeys\x12\x1b.tritontube.ListKeysRequest\x1a\x1c.tritontube.ListKeysResponse\x12N\n" +
+	"\vVerifyFiles\x12\x1e.tritontube.VerifyFilesRequest\x1a\x1f.tritontube.VerifyFilesResponse\x12E\n" +
+	"\bGetStats\x12\x1b.tritontube.GetStatsRequest\x1a\x1c.tritontube.GetStatsResponseB\x16Z\x14internal/proto;protob\x06proto3"
 
 var (
 	file_proto_storage_proto_rawDescOnce sync.Once
@@ -330,27 +738,47 @@ func file_proto_storage_proto_rawDescGZIP() []byte {
 	return file_proto_storage_proto_rawDescData
 }
 
-var file_proto_storage_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_storage_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_proto_storage_proto_goTypes = []any{
-	(*StoreFileRequest)(nil),   // 0: tritontube.StoreFileRequest
-	(*StoreFileResponse)(nil),  // 1: tritontube.StoreFileResponse
-	(*GetFileRequest)(nil),     // 2: tritontube.GetFileRequest
-	(*GetFileResponse)(nil),    // 3: tritontube.GetFileResponse
-	(*DeleteFileRequest)(nil),  // 4: tritontube.DeleteFileRequest
-	(*DeleteFileResponse)(nil), // 5: tritontube.DeleteFileResponse
+	(*StoreFileRequest)(nil),      // 0: tritontube.StoreFileRequest
+	(*StoreFileResponse)(nil),     // 1: tritontube.StoreFileResponse
+	(*GetFileRequest)(nil),        // 2: tritontube.GetFileRequest
+	(*GetFileResponse)(nil),       // 3: tritontube.GetFileResponse
+	(*DeleteFileRequest)(nil),     // 4: tritontube.DeleteFileRequest
+	(*DeleteFileResponse)(nil),    // 5: tritontube.DeleteFileResponse
+	(*ListKeysRequest)(nil),       // 6: tritontube.ListKeysRequest
+	(*ListKeysResponse)(nil),      // 7: tritontube.ListKeysResponse
+	(*VerifyFilesRequest)(nil),    // 8: tritontube.VerifyFilesRequest
+	(*VerifyFilesResponse)(nil),   // 9: tritontube.VerifyFilesResponse
+	(*GetStatsRequest)(nil),       // 10: tritontube.GetStatsRequest
+	(*GetStatsResponse)(nil),      // 11: tritontube.GetStatsResponse
+	nil,                           // 12: tritontube.GetStatsResponse.RequestCountsEntry
+	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
 }
 var file_proto_storage_proto_depIdxs = []int32{
-	0, // 0: tritontube.VideoContentStorageService.StoreFile:input_type -> tritontube.StoreFileRequest
-	2, // 1: tritontube.VideoContentStorageService.GetFile:input_type -> tritontube.GetFileRequest
-	4, // 2: tritontube.VideoContentStorageService.DeleteFile:input_type -> tritontube.DeleteFileRequest
-	1, // 3: tritontube.VideoContentStorageService.StoreFile:output_type -> tritontube.StoreFileResponse
-	3, // 4: tritontube.VideoContentStorageService.GetFile:output_type -> tritontube.GetFileResponse
-	5, // 5: tritontube.VideoContentStorageService.DeleteFile:output_type -> tritontube.DeleteFileResponse
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	12, // 0: tritontube.GetStatsResponse.request_counts:type_name -> tritontube.GetStatsResponse.RequestCountsEntry
+	13, // 1: tritontube.GetStatsResponse.started_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: tritontube.VideoContentStorageService.StoreFile:input_type -> tritontube.StoreFileRequest
+	2,  // 3: tritontube.VideoContentStorageService.GetFile:input_type -> tritontube.GetFileRequest
+	0,  // 4: tritontube.VideoContentStorageService.StoreFileStream:input_type -> tritontube.StoreFileRequest
+	2,  // 5: tritontube.VideoContentStorageService.GetFileStream:input_type -> tritontube.GetFileRequest
+	4,  // 6: tritontube.VideoContentStorageService.DeleteFile:input_type -> tritontube.DeleteFileRequest
+	6,  // 7: tritontube.VideoContentStorageService.ListKeys:input_type -> tritontube.ListKeysRequest
+	8,  // 8: tritontube.VideoContentStorageService.VerifyFiles:input_type -> tritontube.VerifyFilesRequest
+	10, // 9: tritontube.VideoContentStorageService.GetStats:input_type -> tritontube.GetStatsRequest
+	1,  // 10: tritontube.VideoContentStorageService.StoreFile:output_type -> tritontube.StoreFileResponse
+	3,  // 11: tritontube.VideoContentStorageService.GetFile:output_type -> tritontube.GetFileResponse
+	1,  // 12: tritontube.VideoContentStorageService.StoreFileStream:output_type -> tritontube.StoreFileResponse
+	3,  // 13: tritontube.VideoContentStorageService.GetFileStream:output_type -> tritontube.GetFileResponse
+	5,  // 14: tritontube.VideoContentStorageService.DeleteFile:output_type -> tritontube.DeleteFileResponse
+	7,  // 15: tritontube.VideoContentStorageService.ListKeys:output_type -> tritontube.ListKeysResponse
+	9,  // 16: tritontube.VideoContentStorageService.VerifyFiles:output_type -> tritontube.VerifyFilesResponse
+	11, // 17: tritontube.VideoContentStorageService.GetStats:output_type -> tritontube.GetStatsResponse
+	10, // [10:18] is the sub-list for method output_type
+	2,  // [2:10] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_proto_storage_proto_init() }
@@ -364,7 +792,7 @@ func file_proto_storage_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_storage_proto_rawDesc), len(file_proto_storage_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,18 +19,31 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	VideoContentStorageService_StoreFile_FullMethodName  = "/tritontube.VideoContentStorageService/StoreFile"
-	VideoContentStorageService_GetFile_FullMethodName    = "/tritontube.VideoContentStorageService/GetFile"
-	VideoContentStorageService_DeleteFile_FullMethodName = "/tritontube.VideoContentStorageService/DeleteFile"
+	VideoContentStorageService_StoreFile_FullMethodName       = "/tritontube.VideoContentStorageService/StoreFile"
+	VideoContentStorageService_GetFile_FullMethodName         = "/tritontube.VideoContentStorageService/GetFile"
+	VideoContentStorageService_StoreFileStream_FullMethodName = "/tritontube.VideoContentStorageService/StoreFileStream"
+	VideoContentStorageService_GetFileStream_FullMethodName   = "/tritontube.VideoContentStorageService/GetFileStream"
+	VideoContentStorageService_DeleteFile_FullMethodName      = "/tritontube.VideoContentStorageService/DeleteFile"
+	VideoContentStorageService_ListKeys_FullMethodName        = "/tritontube.VideoContentStorageService/ListKeys"
+	VideoContentStorageService_VerifyFiles_FullMethodName     = "/tritontube.VideoContentStorageService/VerifyFiles"
+	VideoContentStorageService_GetStats_FullMethodName        = "/tritontube.VideoContentStorageService/GetStats"
 )
 
 // VideoContentStorageServiceClient is the client API for VideoContentStorageService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type VideoContentStorageServiceClient interface {
+	// StoreFile and GetFile transfer a whole file in one message. They are
+	// kept for web servers that predate the streaming variants, so storage
+	// nodes can be upgraded first.
 	StoreFile(ctx context.Context, in *StoreFileRequest, opts ...grpc.CallOption) (*StoreFileResponse, error)
 	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (*GetFileResponse, error)
+	StoreFileStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StoreFileRequest, StoreFileResponse], error)
+	GetFileStream(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetFileResponse], error)
 	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error)
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error)
+	VerifyFiles(ctx context.Context, in *VerifyFilesRequest, opts ...grpc.CallOption) (*VerifyFilesResponse, error)
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type videoContentStorageServiceClient struct {
@@ -61,6 +74,38 @@ func (c *videoContentStorageServiceClient) GetFile(ctx context.Context, in *GetF
 	return out, nil
 }
 
+func (c *videoContentStorageServiceClient) StoreFileStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StoreFileRequest, StoreFileResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VideoContentStorageService_ServiceDesc.Streams[0], VideoContentStorageService_StoreFileStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StoreFileRequest, StoreFileResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoContentStorageService_StoreFileStreamClient = grpc.ClientStreamingClient[StoreFileRequest, StoreFileResponse]
+
+func (c *videoContentStorageServiceClient) GetFileStream(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetFileResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VideoContentStorageService_ServiceDesc.Streams[1], VideoContentStorageService_GetFileStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetFileRequest, GetFileResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoContentStorageService_GetFileStreamClient = grpc.ServerStreamingClient[GetFileResponse]
+
 func (c *videoContentStorageServiceClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(DeleteFileResponse)
@@ -71,13 +116,51 @@ func (c *videoContentStorageServiceClient) DeleteFile(ctx context.Context, in *D
 	return out, nil
 }
 
+func (c *videoContentStorageServiceClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListKeysResponse)
+	err := c.cc.Invoke(ctx, VideoContentStorageService_ListKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoContentStorageServiceClient) VerifyFiles(ctx context.Context, in *VerifyFilesRequest, opts ...grpc.CallOption) (*VerifyFilesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyFilesResponse)
+	err := c.cc.Invoke(ctx, VideoContentStorageService_VerifyFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoContentStorageServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, VideoContentStorageService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // VideoContentStorageServiceServer is the server API for VideoContentStorageService service.
 // All implementations must embed UnimplementedVideoContentStorageServiceServer
 // for forward compatibility.
 type VideoContentStorageServiceServer interface {
+	// StoreFile and GetFile transfer a whole file in one message. They are
+	// kept for web servers that predate the streaming variants, so storage
+	// nodes can be upgraded first.
 	StoreFile(context.Context, *StoreFileRequest) (*StoreFileResponse, error)
 	GetFile(context.Context, *GetFileRequest) (*GetFileResponse, error)
+	StoreFileStream(grpc.ClientStreamingServer[StoreFileRequest, StoreFileResponse]) error
+	GetFileStream(*GetFileRequest, grpc.ServerStreamingServer[GetFileResponse]) error
 	DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error)
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error)
+	VerifyFiles(context.Context, *VerifyFilesRequest) (*VerifyFilesResponse, error)
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	mustEmbedUnimplementedVideoContentStorageServiceServer()
 }
 
@@ -94,9 +177,24 @@ func (UnimplementedVideoContentStorageServiceServer) StoreFile(context.Context,
 func (UnimplementedVideoContentStorageServiceServer) GetFile(context.Context, *GetFileRequest) (*GetFileResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetFile not implemented")
 }
+func (UnimplementedVideoContentStorageServiceServer) StoreFileStream(grpc.ClientStreamingServer[StoreFileRequest, StoreFileResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StoreFileStream not implemented")
+}
+func (UnimplementedVideoContentStorageServiceServer) GetFileStream(*GetFileRequest, grpc.ServerStreamingServer[GetFileResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetFileStream not implemented")
+}
 func (UnimplementedVideoContentStorageServiceServer) DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteFile not implemented")
 }
+func (UnimplementedVideoContentStorageServiceServer) ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedVideoContentStorageServiceServer) VerifyFiles(context.Context, *VerifyFilesRequest) (*VerifyFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyFiles not implemented")
+}
+func (UnimplementedVideoContentStorageServiceServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
 func (UnimplementedVideoContentStorageServiceServer) mustEmbedUnimplementedVideoContentStorageServiceServer() {
 }
 func (UnimplementedVideoContentStorageServiceServer) testEmbeddedByValue() {}
@@ -155,6 +253,24 @@ func _VideoContentStorageService_GetFile_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VideoContentStorageService_StoreFileStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(VideoContentStorageServiceServer).StoreFileStream(&grpc.GenericServerStream[StoreFileRequest, StoreFileResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoContentStorageService_StoreFileStreamServer = grpc.ClientStreamingServer[StoreFileRequest, StoreFileResponse]
+
+func _VideoContentStorageService_GetFileStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VideoContentStorageServiceServer).GetFileStream(m, &grpc.GenericServerStream[GetFileRequest, GetFileResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoContentStorageService_GetFileStreamServer = grpc.ServerStreamingServer[GetFileResponse]
+
 func _VideoContentStorageService_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteFileRequest)
 	if err := dec(in); err != nil {
@@ -173,6 +289,60 @@ func _VideoContentStorageService_DeleteFile_Handler(srv interface{}, ctx context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VideoContentStorageService_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoContentStorageServiceServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoContentStorageService_ListKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoContentStorageServiceServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoContentStorageService_VerifyFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoContentStorageServiceServer).VerifyFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoContentStorageService_VerifyFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoContentStorageServiceServer).VerifyFiles(ctx, req.(*VerifyFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoContentStorageService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoContentStorageServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoContentStorageService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoContentStorageServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // VideoContentStorageService_ServiceDesc is the grpc.ServiceDesc for VideoContentStorageService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -192,7 +362,30 @@ var VideoContentStorageService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteFile",
 			Handler:    _VideoContentStorageService_DeleteFile_Handler,
 		},
+		{
+			MethodName: "ListKeys",
+			Handler:    _VideoContentStorageService_ListKeys_Handler,
+		},
+		{
+			MethodName: "VerifyFiles",
+			Handler:    _VideoContentStorageService_VerifyFiles_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _VideoContentStorageService_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StoreFileStream",
+			Handler:       _VideoContentStorageService_StoreFileStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetFileStream",
+			Handler:       _VideoContentStorageService_GetFileStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/storage.proto",
 }
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"tritontube/internal/proto"
+)
+
+// The SHA-256 of every stored file is kept in a hidden sidecar file next to it,
+// so it is skipped by ListKeys. A file is replaced by removing its old
+// checksum, moving the new data into place and then the new checksum, so
+// that data is never paired with the checksum of other data, even after a
+// crash; data left without a checksum is reported as unverified. s.filesMutex
+// keeps readers from opening the data of one write and the checksum of
+// another.
+
+func checksumPath(fullPath string) string {
+	return filepath.Join(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".sha256")
+}
+
+// stageChecksum writes the hex-encoded digest of the file at fullPath to a
+// temporary file for replaceFile, and returns its path.
+func stageChecksum(fullPath string, digest []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), filepath.Base(checksumPath(fullPath))+".*.part")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.WriteString(hex.EncodeToString(digest) + "\n")
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// replaceFile moves the staged data and checksum of the file at fullPath into
+// place.
+func (s *StorageServer) replaceFile(fullPath string, dataTmp string, checksumTmp string) error {
+	s.filesMutex.Lock()
+	defer s.filesMutex.Unlock()
+
+	if err := os.Remove(checksumPath(fullPath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(dataTmp, fullPath); err != nil {
+		return err
+	}
+	return os.Rename(checksumTmp, checksumPath(fullPath))
+}
+
+// removeFile deletes the file at fullPath and its checksum.
+func (s *StorageServer) removeFile(fullPath string) error {
+	s.filesMutex.Lock()
+	defer s.filesMutex.Unlock()
+
+	if err := os.Remove(fullPath); err != nil {
+		return err
+	}
+	if err := os.Remove(checksumPath(fullPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete checksum: %v", err)
+	}
+	return nil
+}
+
+// openFile opens the file at fullPath and returns its stored digest, or nil
+// if it was stored without one.
+func (s *StorageServer) openFile(fullPath string) (*os.File, []byte, error) {
+	s.filesMutex.RLock()
+	defer s.filesMutex.RUnlock()
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	expected, err := readChecksum(fullPath)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, expected, nil
+}
+
+// readChecksum returns the stored digest of the file at fullPath, or nil if
+// the file was stored without one.
+func readChecksum(fullPath string) ([]byte, error) {
+	data, err := os.ReadFile(checksumPath(fullPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	digest, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("malformed checksum file %v", checksumPath(fullPath))
+	}
+	return digest, nil
+}
+
+// hashFile returns the SHA-256 of the data of f.
+func hashFile(f *os.File) ([]byte, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+func (s *StorageServer) VerifyFiles(ctx context.Context, req *proto.VerifyFilesRequest) (*proto.VerifyFilesResponse, error) {
+	s.countRequest("VerifyFiles")
+
+	keys, err := s.walkKeys(req.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %v", err)
+	}
+
+	resp := &proto.VerifyFilesResponse{}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fullPath := filepath.Join(s.baseDir, filepath.FromSlash(key))
+
+		f, expected, err := s.openFile(fullPath)
+		if os.IsNotExist(err) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			resp.CorruptKeys = append(resp.CorruptKeys, key)
+			continue
+		}
+		if expected == nil {
+			f.Close()
+			resp.UnverifiedKeys = append(resp.UnverifiedKeys, key)
+			continue
+		}
+
+		resp.CheckedCount++
+		digest, err := hashFile(f)
+		f.Close()
+		if err != nil || !bytes.Equal(digest, expected) {
+			resp.CorruptKeys = append(resp.CorruptKeys, key)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"tritontube/internal/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// countRequest records that the RPC method was called.
+func (s *StorageServer) countRequest(method string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requestCounts[method]++
+}
+
+func (s *StorageServer) GetStats(ctx context.Context, req *proto.GetStatsRequest) (*proto.GetStatsResponse, error) {
+	s.countRequest("GetStats")
+
+	resp := &proto.GetStatsResponse{StartedAt: timestamppb.New(s.startedAt)}
+	err := s.walkFiles("", func(key string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		resp.FileCount++
+		resp.TotalBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan files: %v", err)
+	}
+
+	resp.FreeBytes, err = freeBytes(s.baseDir)
+	if err != nil {
+		resp.FreeBytes = -1
+	}
+
+	s.mutex.Lock()
+	resp.RequestCounts = maps.Clone(s.requestCounts)
+	s.mutex.Unlock()
+	return resp, nil
+}
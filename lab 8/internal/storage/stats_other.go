@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package storage
+
+import "errors"
+
+// freeBytes is not supported on this platform.
+func freeBytes(dir string) (int64, error) {
+	return 0, errors.New("free space is not supported on this platform")
+}
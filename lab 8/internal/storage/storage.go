@@ -3,53 +3,226 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 	"tritontube/internal/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Implement a network video content service (server)
 
+// chunkSize is the amount of file data sent in each GetFileStream response.
+const chunkSize = 1 << 20
+
+const (
+	defaultPageSize = 1000
+	maxPageSize     = 10000
+)
+
 type StorageServer struct {
 	proto.UnimplementedVideoContentStorageServiceServer
 	baseDir string
+
+	startedAt     time.Time
+	mutex         sync.Mutex
+	requestCounts map[string]int64
+
+	// filesMutex orders replacing and deleting files against opening them.
+	filesMutex sync.RWMutex
 }
 
 func NewStorageServer(baseDir string) *StorageServer {
-	return &StorageServer{baseDir: baseDir}
+	return &StorageServer{
+		baseDir:       baseDir,
+		startedAt:     time.Now(),
+		requestCounts: make(map[string]int64),
+	}
 }
 
 func (s *StorageServer) StoreFile(ctx context.Context, req *proto.StoreFileRequest) (*proto.StoreFileResponse, error) {
-	fullPath := filepath.Join(s.baseDir, filepath.Clean(req.Key))
+	s.countRequest("StoreFile")
+
+	last := func() (*proto.StoreFileRequest, error) { return nil, io.EOF }
+	if err := s.storeFile(req, last); err != nil {
+		return &proto.StoreFileResponse{Success: false}, err
+	}
+	return &proto.StoreFileResponse{Success: true}, nil
+}
+
+func (s *StorageServer) StoreFileStream(stream proto.VideoContentStorageService_StoreFileStreamServer) error {
+	s.countRequest("StoreFileStream")
+
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "missing file key")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to receive chunk: %v", err)
+	}
+	if err := s.storeFile(req, stream.Recv); err != nil {
+		return err
+	}
+	return stream.SendAndClose(&proto.StoreFileResponse{Success: true})
+}
+
+// storeFile stores the file whose first message is req, followed by the
+// messages next returns until io.EOF.
+func (s *StorageServer) storeFile(req *proto.StoreFileRequest, next func() (*proto.StoreFileRequest, error)) error {
+	if req.Key == "" {
+		return status.Error(codes.InvalidArgument, "missing file key")
+	}
+	key := req.Key
+	fullPath := filepath.Join(s.baseDir, filepath.Clean(key))
 
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		return &proto.StoreFileResponse{Success: false}, fmt.Errorf("failed to create base dir: %v", err)
+		return fmt.Errorf("failed to create base dir: %v", err)
 	}
 
-	if err := os.WriteFile(fullPath, req.Data, 0644); err != nil {
-		return &proto.StoreFileResponse{Success: false}, fmt.Errorf("failed to write data: %v", err)
+	// Chunks go to a temporary file next to the target, which is renamed into
+	// place once the stream completes so readers never observe a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*.part")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
 	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	return &proto.StoreFileResponse{Success: true}, nil
+	hash := sha256.New()
+	w := io.MultiWriter(tmp, hash)
+	var expected []byte
+	for {
+		if _, err := w.Write(req.Data); err != nil {
+			return fmt.Errorf("failed to write data: %v", err)
+		}
+		if len(req.Sha256) > 0 {
+			expected = req.Sha256
+		}
+		req, err = next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive chunk: %v", err)
+		}
+	}
+
+	digest := hash.Sum(nil)
+	if expected != nil && !bytes.Equal(expected, digest) {
+		return status.Errorf(codes.DataLoss, "checksum mismatch for %v: got %x, want %x", key, digest, expected)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write data: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write data: %v", err)
+	}
+	checksumTmp, err := stageChecksum(fullPath, digest)
+	if err != nil {
+		return fmt.Errorf("failed to write checksum: %v", err)
+	}
+	defer os.Remove(checksumTmp)
+	if err := s.replaceFile(fullPath, tmp.Name(), checksumTmp); err != nil {
+		return fmt.Errorf("failed to write data: %v", err)
+	}
+	return nil
 }
 
 func (s *StorageServer) GetFile(ctx context.Context, req *proto.GetFileRequest) (*proto.GetFileResponse, error) {
-	fullPath := filepath.Join(s.baseDir, filepath.Clean(req.Key))
+	s.countRequest("GetFile")
 
-	data, err := os.ReadFile(fullPath)
+	resp := &proto.GetFileResponse{}
+	first := true
+	err := s.sendFile(req.Key, func(chunk *proto.GetFileResponse) error {
+		if first {
+			resp.Sha256, resp.Size = chunk.Sha256, chunk.Size
+			first = false
+		}
+		resp.Data = append(resp.Data, chunk.Data...)
+		return nil
+	})
 	if err != nil {
-		return &proto.GetFileResponse{Data: nil}, fmt.Errorf("failed to read file: %v", err)
+		return &proto.GetFileResponse{Data: nil}, err
 	}
+	return resp, nil
+}
 
-	return &proto.GetFileResponse{Data: data}, nil
+func (s *StorageServer) GetFileStream(req *proto.GetFileRequest, stream proto.VideoContentStorageService_GetFileStreamServer) error {
+	s.countRequest("GetFileStream")
+	return s.sendFile(req.Key, stream.Send)
+}
+
+// sendFile sends the file at key to send in chunks, the first of which carries
+// its checksum and size. It fails after the last chunk if the data does not
+// match the checksum.
+func (s *StorageServer) sendFile(key string, send func(*proto.GetFileResponse) error) error {
+	fullPath := filepath.Join(s.baseDir, filepath.Clean(key))
+
+	f, expected, err := s.openFile(fullPath)
+	if os.IsNotExist(err) {
+		return status.Errorf(codes.NotFound, "file %v not found", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+
+	// The data is hashed as it is sent, and the stream fails after the last
+	// chunk if it does not match the checksum stored with the file.
+	hash := sha256.New()
+	buf := make([]byte, chunkSize)
+	first := true
+	for {
+		n, err := f.Read(buf)
+		hash.Write(buf[:n])
+		if n > 0 || (first && errors.Is(err, io.EOF)) {
+			resp := &proto.GetFileResponse{Data: buf[:n]}
+			if first {
+				resp.Sha256 = expected
+				resp.Size = info.Size()
+				first = false
+			}
+			if err := send(resp); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file: %v", err)
+		}
+	}
+
+	if expected != nil && !bytes.Equal(expected, hash.Sum(nil)) {
+		return status.Errorf(codes.DataLoss, "checksum mismatch for %v", key)
+	}
+	return nil
 }
 
 func (s *StorageServer) DeleteFile(ctx context.Context, req *proto.DeleteFileRequest) (*proto.DeleteFileResponse, error) {
+	s.countRequest("DeleteFile")
+
 	fullPath := filepath.Join(s.baseDir, filepath.Clean(req.Key))
 
-	if err := os.Remove(fullPath); err != nil {
+	if err := s.removeFile(fullPath); err != nil {
 		if os.IsNotExist(err) {
 			return &proto.DeleteFileResponse{Success: true}, nil
 		}
@@ -58,3 +231,74 @@ func (s *StorageServer) DeleteFile(ctx context.Context, req *proto.DeleteFileReq
 
 	return &proto.DeleteFileResponse{Success: true}, nil
 }
+
+func (s *StorageServer) ListKeys(ctx context.Context, req *proto.ListKeysRequest) (*proto.ListKeysResponse, error) {
+	s.countRequest("ListKeys")
+
+	keys, err := s.walkKeys(req.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %v", err)
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	// The page token is the last key of the previous page.
+	start := 0
+	if req.PageToken != "" {
+		start, _ = slices.BinarySearch(keys, req.PageToken)
+		if start < len(keys) && keys[start] == req.PageToken {
+			start++
+		}
+	}
+	end := min(start+pageSize, len(keys))
+
+	resp := &proto.ListKeysResponse{Keys: keys[start:end]}
+	if end < len(keys) {
+		resp.NextPageToken = keys[end-1]
+	}
+	return resp, nil
+}
+
+// walkKeys returns the sorted keys of all files under baseDir that start with
+// prefix.
+func (s *StorageServer) walkKeys(prefix string) ([]string, error) {
+	var keys []string
+	err := s.walkFiles(prefix, func(key string, d fs.DirEntry) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// walkFiles calls fn for every file under baseDir whose key starts with
+// prefix. Hidden files, such as in-progress uploads, are skipped.
+func (s *StorageServer) walkFiles(prefix string, fn func(key string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(s.baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == s.baseDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		return fn(key, d)
+	})
+}
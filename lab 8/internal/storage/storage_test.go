@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net"
+	"testing"
+	"tritontube/internal/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// startServer runs a storage server on a temporary directory and returns a
+// client of it and the directory.
+func startServer(t *testing.T) (proto.VideoContentStorageServiceClient, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	dir := t.TempDir()
+	srv := grpc.NewServer()
+	proto.RegisterVideoContentStorageServiceServer(srv, NewStorageServer(dir))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewVideoContentStorageServiceClient(conn), dir
+}
+
+func storeStream(ctx context.Context, client proto.VideoContentStorageServiceClient, key string, chunks ...[]byte) error {
+	stream, err := client.StoreFileStream(ctx)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	for i, chunk := range chunks {
+		req := &proto.StoreFileRequest{Data: chunk}
+		if i == 0 {
+			req.Key = key
+		}
+		if err := stream.Send(req); err != nil {
+			break
+		}
+		hash.Write(chunk)
+	}
+	stream.Send(&proto.StoreFileRequest{Sha256: hash.Sum(nil)})
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+func getStream(ctx context.Context, client proto.VideoContentStorageServiceClient, key string) ([]byte, error) {
+	stream, err := client.GetFileStream(ctx, &proto.GetFileRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, resp.Data...)
+	}
+}
+
+// TestUnaryAndStreamingFiles checks that files stored by either the unary or
+// the streaming RPCs can be read by both, as web servers of different
+// versions share the nodes during an upgrade.
+func TestUnaryAndStreamingFiles(t *testing.T) {
+	client, _ := startServer(t)
+	ctx := context.Background()
+
+	unary := []byte("stored in one message")
+	if _, err := client.StoreFile(ctx, &proto.StoreFileRequest{Key: "v1/unary.m4s", Data: unary}); err != nil {
+		t.Fatalf("StoreFile: %v", err)
+	}
+	streamed := bytes.Repeat([]byte("chunk "), 100)
+	if err := storeStream(ctx, client, "v1/stream.m4s", streamed[:300], streamed[300:]); err != nil {
+		t.Fatalf("StoreFileStream: %v", err)
+	}
+
+	for key, want := range map[string][]byte{"v1/unary.m4s": unary, "v1/stream.m4s": streamed} {
+		resp, err := client.GetFile(ctx, &proto.GetFileRequest{Key: key})
+		if err != nil {
+			t.Fatalf("GetFile %v: %v", key, err)
+		}
+		if !bytes.Equal(resp.Data, want) || resp.Size != int64(len(want)) {
+			t.Errorf("GetFile %v = %q (size %d), want %q", key, resp.Data, resp.Size, want)
+		}
+		sum := sha256.Sum256(want)
+		if !bytes.Equal(resp.Sha256, sum[:]) {
+			t.Errorf("GetFile %v checksum = %x, want %x", key, resp.Sha256, sum)
+		}
+
+		got, err := getStream(ctx, client, key)
+		if err != nil {
+			t.Fatalf("GetFileStream %v: %v", key, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("GetFileStream %v = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestStoreFileRejectsChecksumMismatch(t *testing.T) {
+	client, _ := startServer(t)
+	ctx := context.Background()
+
+	req := &proto.StoreFileRequest{Key: "v1/bad.m4s", Data: []byte("data"), Sha256: make([]byte, sha256.Size)}
+	if _, err := client.StoreFile(ctx, req); status.Code(err) != codes.DataLoss {
+		t.Fatalf("StoreFile error = %v, want DataLoss", err)
+	}
+	if _, err := client.GetFile(ctx, &proto.GetFileRequest{Key: "v1/bad.m4s"}); err == nil {
+		t.Errorf("GetFile of a rejected file succeeded")
+	}
+}
+
+// TestOverwriteKeepsChecksum overwrites a file while it is read and verified,
+// which must never pair its data with the checksum of another write.
+func TestOverwriteKeepsChecksum(t *testing.T) {
+	client, _ := startServer(t)
+	ctx := context.Background()
+
+	versions := [][]byte{bytes.Repeat([]byte("a"), 4096), bytes.Repeat([]byte("b"), 8192)}
+	if err := storeStream(ctx, client, "v1/seg.m4s", versions[0]); err != nil {
+		t.Fatalf("StoreFileStream: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		for i := 1; i <= 50; i++ {
+			if err := storeStream(ctx, client, "v1/seg.m4s", versions[i%2]); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for running := true; running; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("StoreFileStream: %v", err)
+			}
+			running = false
+		default:
+		}
+
+		resp, err := client.VerifyFiles(ctx, &proto.VerifyFilesRequest{})
+		if err != nil {
+			t.Fatalf("VerifyFiles: %v", err)
+		}
+		if len(resp.CorruptKeys) > 0 || len(resp.UnverifiedKeys) > 0 {
+			t.Fatalf("VerifyFiles = corrupt %v, unverified %v, want none", resp.CorruptKeys, resp.UnverifiedKeys)
+		}
+		data, err := getStream(ctx, client, "v1/seg.m4s")
+		if err != nil {
+			t.Fatalf("GetFileStream: %v", err)
+		}
+		if !bytes.Equal(data, versions[0]) && !bytes.Equal(data, versions[1]) {
+			t.Fatalf("GetFileStream returned %d bytes of mixed data", len(data))
+		}
+	}
+}
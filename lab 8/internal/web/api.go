@@ -0,0 +1,190 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// apiPrefix is the path under which the JSON API is served.
+const apiPrefix = "/api/v1"
+
+const (
+	defaultAPIPageSize = 20
+	maxAPIPageSize     = 100
+)
+
+// apiVideo is the JSON representation of a video.
+type apiVideo struct {
+	Id               string    `json:"id"`
+	UploadedAt       time.Time `json:"uploaded_at"`
+	Title            string    `json:"title"`
+	Description      string    `json:"description"`
+	DurationSeconds  float64   `json:"duration_seconds"`
+	Width            int       `json:"width"`
+	Height           int       `json:"height"`
+	VideoCodec       string    `json:"video_codec"`
+	AudioCodec       string    `json:"audio_codec,omitempty"`
+	FrameRate        float64   `json:"frame_rate"`
+	OriginalSize     int64     `json:"original_size"`
+	OriginalFilename string    `json:"original_filename"`
+	ContentHash      string    `json:"content_hash,omitempty"`
+	ManifestURL      string    `json:"manifest_url"`
+	HLSURL           string    `json:"hls_url"`
+	PosterURL        string    `json:"poster_url"`
+}
+
+func newAPIVideo(meta VideoMetadata) apiVideo {
+	return apiVideo{
+		Id:               meta.Id,
+		UploadedAt:       meta.UploadedAt,
+		Title:            meta.Title,
+		Description:      meta.Description,
+		DurationSeconds:  meta.Duration.Seconds(),
+		Width:            meta.Width,
+		Height:           meta.Height,
+		VideoCodec:       meta.VideoCodec,
+		AudioCodec:       meta.AudioCodec,
+		FrameRate:        meta.FrameRate,
+		OriginalSize:     meta.OriginalSize,
+		OriginalFilename: meta.OriginalFilename,
+		ContentHash:      meta.ContentHash,
+		ManifestURL:      "/content/" + meta.Id + "/manifest.mpd",
+		HLSURL:           "/content/" + meta.Id + "/master.m3u8",
+		PosterURL:        "/content/" + meta.Id + "/" + posterFilename,
+	}
+}
+
+// apiError is the body of every failed API response.
+type apiError struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func writeAPIError(w http.ResponseWriter, code int, msg string) {
+	var body apiError
+	body.Error.Status = code
+	body.Error.Message = msg
+	writeJSON(w, code, body)
+}
+
+// registerAPI adds the JSON API routes to mux.
+func (s *server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+apiPrefix+"/videos", s.apiListVideos)
+	mux.HandleFunc("POST "+apiPrefix+"/videos", s.apiUploadVideo)
+	mux.HandleFunc("GET "+apiPrefix+"/videos/{id}", s.apiGetVideo)
+	mux.HandleFunc("DELETE "+apiPrefix+"/videos/{id}", s.apiDeleteVideo)
+	mux.HandleFunc("GET "+apiPrefix+"/jobs/{id}", s.apiGetJob)
+	mux.HandleFunc("GET "+apiPrefix+"/cache", s.apiGetCacheStats)
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "Unknown API endpoint")
+	})
+}
+
+// apiListVideos returns a page of videos. The q, sort and order parameters
+// filter and order them as in VideoQuery, limit sets the page size, and the
+// returned next_page_token and prev_page_token are passed as page_token to
+// request the adjacent pages.
+func (s *server) apiListVideos(w http.ResponseWriter, r *http.Request) {
+	query := videoQueryFrom(r)
+	query.Limit = defaultAPIPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		query.Limit = min(n, maxAPIPageSize)
+	}
+
+	page, err := s.metadataService.List(query)
+	if errors.Is(err, ErrInvalidQuery) {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Println("List metadata error: ", err)
+		writeAPIError(w, http.StatusInternalServerError, "Failed to read video list")
+		return
+	}
+
+	resp := struct {
+		Videos        []apiVideo `json:"videos"`
+		NextPageToken string     `json:"next_page_token,omitempty"`
+		PrevPageToken string     `json:"prev_page_token,omitempty"`
+	}{
+		Videos:        []apiVideo{},
+		NextPageToken: page.NextCursor,
+		PrevPageToken: page.PrevCursor,
+	}
+	for _, meta := range page.Videos {
+		resp.Videos = append(resp.Videos, newAPIVideo(meta))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) apiGetVideo(w http.ResponseWriter, r *http.Request) {
+	meta, err := s.metadataService.Read(r.PathValue("id"))
+	if errors.Is(err, ErrVideoNotFound) {
+		writeAPIError(w, http.StatusNotFound, "Video not found")
+		return
+	}
+	if err != nil {
+		log.Println("Read metadata error: ", err)
+		writeAPIError(w, http.StatusInternalServerError, "Failed to read video metadata")
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIVideo(*meta))
+}
+
+// apiUploadVideo queues the multipart "file" upload, titled by the optional
+// "title" and "description" fields, for transcoding and returns the job, whose
+// progress is available at the Location header.
+func (s *server) apiUploadVideo(w http.ResponseWriter, r *http.Request) {
+	job, err := s.enqueueUpload(r)
+	if err != nil {
+		code, msg := errorStatus(err)
+		if code == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "60")
+		}
+		writeAPIError(w, code, msg)
+		return
+	}
+	w.Header().Set("Location", apiPrefix+"/jobs/"+job.Id)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *server) apiDeleteVideo(w http.ResponseWriter, r *http.Request) {
+	videoId := r.PathValue("id")
+	log.Println("DELETE Video ID:", videoId)
+
+	if err := s.deleteVideo(videoId); err != nil {
+		code, msg := errorStatus(err)
+		writeAPIError(w, code, msg)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) apiGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// apiGetCacheStats returns the counters of the content cache, if enabled.
+func (s *server) apiGetCacheStats(w http.ResponseWriter, r *http.Request) {
+	cache, ok := s.contentService.(*CachingVideoContentService)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Content cache is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, cache.Stats())
+}
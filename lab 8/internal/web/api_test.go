@@ -0,0 +1,47 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// failingMetadataService fails every Read with err.
+type failingMetadataService struct {
+	VideoMetadataService
+	err error
+}
+
+func (s failingMetadataService) Read(videoId string) (*VideoMetadata, error) {
+	return nil, s.err
+}
+
+func TestAPIGetVideoErrors(t *testing.T) {
+	metadata, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		metadata VideoMetadataService
+		want     int
+	}{
+		{"missing", metadata, http.StatusNotFound},
+		{"failed", failingMetadataService{metadata, errors.New("database is locked")}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(tt.metadata, failingContentService{}, ServerOptions{UploadDir: t.TempDir()})
+			mux := http.NewServeMux()
+			s.registerAPI(mux)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, apiPrefix+"/videos/intro", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %v, want %v", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package web
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// CachingVideoContentService keeps recently read files of another
+// VideoContentService in memory, up to a byte budget, evicting the least
+// recently used files first. Concurrent reads of a file that is not cached
+// share a single read from the underlying service.
+type CachingVideoContentService struct {
+	next     VideoContentService
+	maxBytes int64
+	// maxEntryBytes is the size of the largest file cached. Larger files are
+	// streamed from the underlying service so they cannot flush the cache.
+	maxEntryBytes int64
+
+	mutex   sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	size    int64
+	// loads holds the reads from the underlying service in progress.
+	loads map[string]*cacheLoad
+	stats CacheStats
+}
+
+// CacheStats counts the reads served by a CachingVideoContentService.
+type CacheStats struct {
+	// Hits counts reads served from memory, including those that waited for
+	// a concurrent read of the same file.
+	Hits int64 `json:"hits"`
+	// Misses counts reads from the underlying service.
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+	// MaxEntryBytes is the size of the largest file cached.
+	MaxEntryBytes int64 `json:"max_entry_bytes"`
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+// cacheLoad is a read from the underlying service that other readers of the
+// same file wait for.
+type cacheLoad struct {
+	done chan struct{}
+	data []byte
+	err  error
+	// bypass is set if the file is too large to cache, in which case each
+	// waiter reads it from the underlying service itself.
+	bypass bool
+}
+
+var _ StreamingVideoContentService = (*CachingVideoContentService)(nil)
+
+// NewCachingVideoContentService caches up to maxBytes of the files of next,
+// leaving out files larger than maxEntryBytes.
+func NewCachingVideoContentService(next VideoContentService, maxBytes int64, maxEntryBytes int64) *CachingVideoContentService {
+	return &CachingVideoContentService{
+		next:          next,
+		maxBytes:      maxBytes,
+		maxEntryBytes: min(maxEntryBytes, maxBytes),
+		lru:           list.New(),
+		entries:       make(map[string]*list.Element),
+		loads:         make(map[string]*cacheLoad),
+	}
+}
+
+// Stats returns the cache's counters.
+func (s *CachingVideoContentService) Stats() CacheStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := s.stats
+	stats.Entries = len(s.entries)
+	stats.Bytes = s.size
+	stats.MaxBytes = s.maxBytes
+	stats.MaxEntryBytes = s.maxEntryBytes
+	return stats
+}
+
+func (s *CachingVideoContentService) Read(videoId string, filename string) ([]byte, error) {
+	r, _, err := s.Open(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (s *CachingVideoContentService) Open(videoId string, filename string) (io.ReadCloser, int64, error) {
+	key := fmt.Sprintf("%v/%v", videoId, filename)
+	s.mutex.Lock()
+	if elem, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(elem)
+		s.stats.Hits++
+		s.mutex.Unlock()
+		data := elem.Value.(*cacheEntry).data
+		return bytesReadCloser{bytes.NewReader(data)}, int64(len(data)), nil
+	}
+	load, loading := s.loads[key]
+	if !loading {
+		load = &cacheLoad{done: make(chan struct{})}
+		s.loads[key] = load
+		s.stats.Misses++
+		s.mutex.Unlock()
+		return s.load(key, load, videoId, filename)
+	}
+	s.mutex.Unlock()
+
+	<-load.done
+	if load.bypass {
+		s.mutex.Lock()
+		s.stats.Misses++
+		s.mutex.Unlock()
+		return openContent(s.next, videoId, filename)
+	}
+	if load.err != nil {
+		return nil, 0, load.err
+	}
+	s.mutex.Lock()
+	s.stats.Hits++
+	s.mutex.Unlock()
+	return bytesReadCloser{bytes.NewReader(load.data)}, int64(len(load.data)), nil
+}
+
+// load reads the file at key from the underlying service for the waiters of
+// load, and caches it unless it was invalidated meanwhile.
+func (s *CachingVideoContentService) load(key string, load *cacheLoad, videoId string, filename string) (io.ReadCloser, int64, error) {
+	r, size, err := openContent(s.next, videoId, filename)
+	if err == nil && size > s.maxEntryBytes {
+		load.bypass = true
+		s.finishLoad(key, load)
+		return r, size, nil
+	}
+	if err == nil {
+		load.data, err = io.ReadAll(r)
+		r.Close()
+	}
+	load.err = err
+	s.finishLoad(key, load)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytesReadCloser{bytes.NewReader(load.data)}, int64(len(load.data)), nil
+}
+
+func (s *CachingVideoContentService) finishLoad(key string, load *cacheLoad) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.loads[key] == load {
+		delete(s.loads, key)
+		if load.err == nil && !load.bypass {
+			s.add(key, load.data)
+		}
+	}
+	close(load.done)
+}
+
+// add caches data under key, evicting the least recently used files to make
+// room. The caller must hold s.mutex.
+func (s *CachingVideoContentService) add(key string, data []byte) {
+	s.remove(key)
+	for s.size+int64(len(data)) > s.maxBytes && s.lru.Len() > 0 {
+		s.remove(s.lru.Back().Value.(*cacheEntry).key)
+		s.stats.Evictions++
+	}
+	s.entries[key] = s.lru.PushFront(&cacheEntry{key: key, data: data})
+	s.size += int64(len(data))
+}
+
+// remove drops key from the cache. The caller must hold s.mutex.
+func (s *CachingVideoContentService) remove(key string) {
+	if elem, ok := s.entries[key]; ok {
+		s.lru.Remove(elem)
+		delete(s.entries, key)
+		s.size -= int64(len(elem.Value.(*cacheEntry).data))
+	}
+}
+
+// invalidate drops the cached files whose keys match, and keeps reads of
+// them in progress from caching what they read.
+func (s *CachingVideoContentService) invalidate(match func(key string) bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key := range s.entries {
+		if match(key) {
+			s.remove(key)
+		}
+	}
+	for key := range s.loads {
+		if match(key) {
+			delete(s.loads, key)
+		}
+	}
+}
+
+func (s *CachingVideoContentService) invalidateFile(videoId string, filename string) {
+	key := fmt.Sprintf("%v/%v", videoId, filename)
+	s.invalidate(func(k string) bool { return k == key })
+}
+
+func (s *CachingVideoContentService) Write(videoId string, filename string, data []byte) error {
+	defer s.invalidateFile(videoId, filename)
+	return s.next.Write(videoId, filename, data)
+}
+
+func (s *CachingVideoContentService) Create(videoId string, filename string) (io.WriteCloser, error) {
+	w, err := createContent(s.next, videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	return &cachingWriter{WriteCloser: w, invalidate: func() { s.invalidateFile(videoId, filename) }}, nil
+}
+
+func (s *CachingVideoContentService) CheckWritable() error {
+	return checkWritable(s.next)
+}
+
+func (s *CachingVideoContentService) Delete(videoId string) error {
+	prefix := videoId + "/"
+	defer s.invalidate(func(key string) bool { return strings.HasPrefix(key, prefix) })
+	return s.next.Delete(videoId)
+}
+
+// cachingWriter invalidates the cached copy of the file it writes once the
+// file is stored.
+type cachingWriter struct {
+	io.WriteCloser
+	invalidate func()
+}
+
+func (w *cachingWriter) Close() error {
+	defer w.invalidate()
+	return w.WriteCloser.Close()
+}
+
+func (w *cachingWriter) CloseWithError(err error) error {
+	closeContent(w.WriteCloser, err)
+	return nil
+}
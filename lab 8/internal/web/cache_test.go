@@ -0,0 +1,179 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingContentService counts the files opened on an FS service and, if
+// gate is set, holds each opened file until gate is closed.
+type countingContentService struct {
+	*FSVideoContentService
+	opens   atomic.Int32
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func newCountingContentService(t *testing.T, files map[string]string) *countingContentService {
+	t.Helper()
+	fs, err := NewFSVideoContentService(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSVideoContentService: %v", err)
+	}
+	for key, data := range files {
+		videoId, filename, _ := strings.Cut(key, "/")
+		if err := fs.Write(videoId, filename, []byte(data)); err != nil {
+			t.Fatalf("Write %v: %v", key, err)
+		}
+	}
+	return &countingContentService{FSVideoContentService: fs, entered: make(chan struct{}, 16)}
+}
+
+func (s *countingContentService) Open(videoId string, filename string) (io.ReadCloser, int64, error) {
+	s.opens.Add(1)
+	r, size, err := s.FSVideoContentService.Open(videoId, filename)
+	s.entered <- struct{}{}
+	if s.gate != nil {
+		<-s.gate
+	}
+	return r, size, err
+}
+
+// readCached reads a file through s and checks its contents.
+func readCached(t *testing.T, s *CachingVideoContentService, key string, want string) {
+	t.Helper()
+	videoId, filename, _ := strings.Cut(key, "/")
+	data, err := s.Read(videoId, filename)
+	if err != nil {
+		t.Fatalf("Read %v: %v", key, err)
+	}
+	if string(data) != want {
+		t.Fatalf("Read %v = %q, want %q", key, data, want)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	files := map[string]string{
+		"a/f": "aaaaaaaaaa",
+		"b/f": "bbbbbbbbbb",
+		"c/f": "cccccccccc",
+		"d/f": "dddddddddd",
+		"e/f": strings.Repeat("e", 21),
+	}
+	next := newCountingContentService(t, files)
+	s := NewCachingVideoContentService(next, 30, 20)
+
+	for _, key := range []string{"a/f", "b/f", "c/f", "a/f"} {
+		readCached(t, s, key, files[key])
+	}
+	// d takes the place of b, the least recently used.
+	readCached(t, s, "d/f", files["d/f"])
+	if stats := s.Stats(); stats.Evictions != 1 || stats.Entries != 3 || stats.Bytes != 30 {
+		t.Fatalf("stats after filling the cache = %+v, want 1 eviction and 3 entries of 30 bytes", stats)
+	}
+	opens := next.opens.Load()
+	for _, key := range []string{"a/f", "c/f", "d/f"} {
+		readCached(t, s, key, files[key])
+	}
+	if n := next.opens.Load() - opens; n != 0 {
+		t.Errorf("reads of cached files opened %d files", n)
+	}
+	readCached(t, s, "b/f", files["b/f"])
+	if n := next.opens.Load() - opens; n != 1 {
+		t.Errorf("read of the evicted file opened %d files, want 1", n)
+	}
+
+	// A file larger than the largest entry is never cached.
+	readCached(t, s, "e/f", files["e/f"])
+	readCached(t, s, "e/f", files["e/f"])
+	if n := next.opens.Load() - opens; n != 3 {
+		t.Errorf("reads of the large file opened %d files, want 2", n-1)
+	}
+	if stats := s.Stats(); stats.Bytes > stats.MaxBytes || stats.MaxEntryBytes != 20 {
+		t.Errorf("stats = %+v, want at most 30 bytes and largest entry 20", stats)
+	}
+}
+
+func TestCacheCoalescesConcurrentReads(t *testing.T) {
+	next := newCountingContentService(t, map[string]string{"a/f": "shared"})
+	next.gate = make(chan struct{})
+	s := NewCachingVideoContentService(next, 1<<20, 1<<20)
+
+	const n = 8
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readCached(t, s, "a/f", "shared")
+		}()
+	}
+	// Reads that arrive while the first one is held wait for it.
+	<-next.entered
+	time.Sleep(50 * time.Millisecond)
+	close(next.gate)
+	wg.Wait()
+
+	if opens := next.opens.Load(); opens != 1 {
+		t.Errorf("%d concurrent reads opened the file %d times, want once", n, opens)
+	}
+	if stats := s.Stats(); stats.Misses != 1 || stats.Hits != n-1 {
+		t.Errorf("stats = %+v, want 1 miss and %d hits", stats, n-1)
+	}
+}
+
+func TestCacheInvalidatesOnChange(t *testing.T) {
+	next := newCountingContentService(t, map[string]string{"a/f": "old", "a/g": "other", "b/f": "kept"})
+	s := NewCachingVideoContentService(next, 1<<20, 1<<20)
+
+	for key, data := range map[string]string{"a/f": "old", "a/g": "other", "b/f": "kept"} {
+		readCached(t, s, key, data)
+	}
+	if err := s.Write("a", "f", []byte("new")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	readCached(t, s, "a/f", "new")
+
+	// Deleting a video drops all of its files, and only its files.
+	if err := s.Delete("a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if stats := s.Stats(); stats.Entries != 1 {
+		t.Errorf("entries after Delete = %d, want 1", stats.Entries)
+	}
+	if _, err := s.Read("a", "g"); !errors.Is(err, ErrContentNotFound) {
+		t.Errorf("Read after Delete error = %v, want ErrContentNotFound", err)
+	}
+	opens := next.opens.Load()
+	readCached(t, s, "b/f", "kept")
+	if next.opens.Load() != opens {
+		t.Errorf("Delete dropped the cached files of another video")
+	}
+
+	// A read in progress when its video is deleted does not cache what it
+	// read.
+	if err := s.Write("c", "f", []byte("gone")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	next.gate = make(chan struct{})
+	next.entered = make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Read("c", "f")
+	}()
+	<-next.entered
+	if err := s.Delete("c"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	close(next.gate)
+	<-done
+	if _, err := s.Read("c", "f"); !errors.Is(err, ErrContentNotFound) {
+		t.Errorf("Read after Delete during a read error = %v, want ErrContentNotFound", err)
+	}
+}
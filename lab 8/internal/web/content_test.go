@@ -0,0 +1,164 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// streamOnlyContentService hides the io.Seeker of the files it opens, like
+// the network service's streams.
+type streamOnlyContentService struct {
+	*FSVideoContentService
+}
+
+func (s streamOnlyContentService) Open(videoId string, filename string) (io.ReadCloser, int64, error) {
+	r, size, err := s.FSVideoContentService.Open(videoId, filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	return struct{ io.ReadCloser }{r}, size, nil
+}
+
+func TestVideoContentRanges(t *testing.T) {
+	fs, err := NewFSVideoContentService(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSVideoContentService: %v", err)
+	}
+	data := "0123456789abcdefghij"
+	if err := fs.Write("intro", "segment.m4s", []byte(data)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	metadata, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+
+	get := func(t *testing.T, svc VideoContentService, ranges string) *http.Response {
+		t.Helper()
+		s := NewServer(metadata, svc, ServerOptions{UploadDir: t.TempDir()})
+		req := httptest.NewRequest(http.MethodGet, "/content/intro/segment.m4s", nil)
+		if ranges != "" {
+			req.Header.Set("Range", ranges)
+		}
+		rec := httptest.NewRecorder()
+		s.handleVideoContent(rec, req)
+		return rec.Result()
+	}
+	body := func(t *testing.T, r io.Reader) string {
+		t.Helper()
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		return string(b)
+	}
+
+	services := map[string]VideoContentService{
+		"fs":     fs,
+		"cached": NewCachingVideoContentService(fs, 1<<20, 1<<20),
+		"stream": streamOnlyContentService{fs},
+	}
+	for name, svc := range services {
+		t.Run(name, func(t *testing.T) {
+			resp := get(t, svc, "bytes=10-12")
+			if resp.StatusCode != http.StatusPartialContent {
+				t.Fatalf("single range status = %v, want 206", resp.StatusCode)
+			}
+			if got := body(t, resp.Body); got != data[10:13] {
+				t.Errorf("single range body = %q, want %q", got, data[10:13])
+			}
+
+			resp = get(t, svc, "bytes=10-12,0-2")
+			if name == "stream" {
+				// A stream cannot seek back, so it serves the whole file.
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("multiple range status = %v, want 200", resp.StatusCode)
+				}
+				if got := body(t, resp.Body); got != data {
+					t.Errorf("multiple range body = %q, want %q", got, data)
+				}
+				return
+			}
+			if resp.StatusCode != http.StatusPartialContent {
+				t.Fatalf("multiple range status = %v, want 206", resp.StatusCode)
+			}
+			_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatalf("parse Content-Type: %v", err)
+			}
+			parts := multipart.NewReader(resp.Body, params["boundary"])
+			for _, want := range []string{data[10:13], data[0:3]} {
+				part, err := parts.NextPart()
+				if err != nil {
+					t.Fatalf("next part: %v", err)
+				}
+				if got := body(t, part); got != want {
+					t.Errorf("part = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+// failingContentService fails every request with err.
+type failingContentService struct {
+	err error
+}
+
+func (s failingContentService) Read(videoId string, filename string) ([]byte, error) {
+	return nil, s.err
+}
+
+func (s failingContentService) Write(videoId string, filename string, data []byte) error {
+	return s.err
+}
+
+func (s failingContentService) Delete(videoId string) error {
+	return s.err
+}
+
+func TestVideoContentErrors(t *testing.T) {
+	fs, err := NewFSVideoContentService(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSVideoContentService: %v", err)
+	}
+	addrs, _ := startStorageNodes(t, 2)
+	nw := NewNetworkVideoContentService(2, 16)
+	if err := nw.InitNodes(addrs); err != nil {
+		t.Fatalf("InitNodes: %v", err)
+	}
+	metadata, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		svc  VideoContentService
+		want int
+	}{
+		{"fs missing", fs, http.StatusNotFound},
+		{"stream missing", streamOnlyContentService{fs}, http.StatusNotFound},
+		{"cached missing", NewCachingVideoContentService(fs, 1<<20, 1<<20), http.StatusNotFound},
+		{"network missing", nw, http.StatusNotFound},
+		{"unavailable", failingContentService{fmt.Errorf("%w: node is down", ErrContentUnavailable)}, http.StatusServiceUnavailable},
+		{"failed", failingContentService{errors.New("disk error")}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(metadata, tt.svc, ServerOptions{UploadDir: t.TempDir()})
+			rec := httptest.NewRecorder()
+			s.handleVideoContent(rec, httptest.NewRequest(http.MethodGet, "/content/intro/segment.m4s", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %v, want %v", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Uploads are deduplicated by content hash: a byte-identical upload of an
+// earlier video only gets its own metadata pointing at that video's transcoded
+// files. The metadata service counts the videos referencing each content as it
+// creates and deletes them, and the files are deleted with the last one.
+// Content is stored under an ID unique to its transcode, so deleted content is
+// never confused with a later upload of the same file.
+
+// claimContent records job's video as sharing the content of an earlier
+// byte-identical upload and returns true, or claims the job's content hash so
+// that identical uploads wait for this job instead of transcoding the same
+// file again. A claim is released with releaseContent.
+func (s *server) claimContent(job *transcodeJob) (bool, error) {
+	hash := job.meta.ContentHash
+	if hash == "" {
+		return false, nil
+	}
+	for {
+		s.contentMutex.Lock()
+		existing, err := s.metadataService.FindByContentHash(hash)
+		if err == nil {
+			err := s.metadataService.Create(sharedMetadata(job, existing))
+			s.contentMutex.Unlock()
+			if errors.Is(err, errContentDeleted) {
+				// The existing video was deleted meanwhile, so look again.
+				continue
+			}
+			if err != nil {
+				return true, fmt.Errorf("failed to write metadata: %v", err)
+			}
+			log.Printf("Video %v has the same content as %v", job.VideoId, existing.Id)
+			return true, nil
+		}
+		if !errors.Is(err, ErrVideoNotFound) {
+			s.contentMutex.Unlock()
+			return false, fmt.Errorf("failed to look up content: %v", err)
+		}
+
+		wait, claimed := s.contentClaims[hash]
+		if !claimed {
+			s.contentClaims[hash] = make(chan struct{})
+			s.contentMutex.Unlock()
+			return false, nil
+		}
+		s.contentMutex.Unlock()
+		<-wait
+	}
+}
+
+// releaseContent releases the claim on hash taken by claimContent.
+func (s *server) releaseContent(hash string) {
+	s.contentMutex.Lock()
+	defer s.contentMutex.Unlock()
+
+	if wait, ok := s.contentClaims[hash]; ok {
+		close(wait)
+		delete(s.contentClaims, hash)
+	}
+}
+
+// sharedMetadata returns the metadata of job's video when it shares the
+// content of existing: the upload's own details with existing's media details.
+func sharedMetadata(job *transcodeJob, existing *VideoMetadata) VideoMetadata {
+	meta := *existing
+	meta.Id = job.VideoId
+	meta.UploadedAt = job.CreatedAt
+	meta.Title = job.meta.Title
+	meta.Description = job.meta.Description
+	meta.OriginalSize = job.meta.OriginalSize
+	meta.OriginalFilename = job.meta.OriginalFilename
+	meta.ContentId = contentIdOf(existing)
+	return meta
+}
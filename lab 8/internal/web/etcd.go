@@ -2,8 +2,422 @@
 
 package web
 
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdVideoPrefix is the prefix of the keys video metadata is stored under,
+// followed by the video ID.
+const etcdVideoPrefix = "/tritontube/videos/"
+
+// etcdContentPrefix is the prefix of the keys counting the videos that
+// reference each content, followed by the content ID. Content whose last video
+// was deleted keeps its key with count 0, so that no new video shares it.
+const etcdContentPrefix = "/tritontube/content/"
+
+// etcdHashPrefix is the prefix of the index of videos by content hash,
+// followed by the hash, a slash and the video ID. Each key holds a copy of the
+// video's metadata, so that a lookup by hash is a single request.
+const etcdHashPrefix = "/tritontube/hashes/"
+
+// etcdTxnOps is the most operations put in one transaction, below etcd's
+// default limit of 128.
+const etcdTxnOps = 100
+
+// etcdTimeout bounds each request to etcd.
+const etcdTimeout = 5 * time.Second
+
 type EtcdVideoMetadataService struct {
+	client *clientv3.Client
 }
 
 // Uncomment the following line to ensure EtcdVideoMetadataService implements VideoMetadataService
-// var _ VideoMetadataService = (*EtcdVideoMetadataService)(nil)
+var _ VideoMetadataService = (*EtcdVideoMetadataService)(nil)
+
+func NewEtcdVideoMetadataService(endpoints []string) (*EtcdVideoMetadataService, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to etcd failed: %v", err)
+	}
+
+	// Fail at startup rather than on the first request if etcd is down.
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	if _, err := client.Get(ctx, etcdVideoPrefix, clientv3.WithCountOnly()); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to etcd failed: %v", err)
+	}
+	s := &EtcdVideoMetadataService{client: client}
+	if err := s.backfill(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// backfill adds the reference counts and content hash index keys missing for
+// videos stored before they were kept. The keys are written only if no video
+// changed since they were computed, and computed again otherwise.
+func (s *EtcdVideoMetadataService) backfill(ctx context.Context) error {
+	for {
+		videos, rev, err := s.all(ctx)
+		if err != nil {
+			return err
+		}
+		counted, err := s.keys(ctx, etcdContentPrefix)
+		if err != nil {
+			return err
+		}
+		indexed, err := s.keys(ctx, etcdHashPrefix)
+		if err != nil {
+			return err
+		}
+
+		refs := make(map[string]int)
+		var ops []clientv3.Op
+		for _, meta := range videos {
+			contentId := contentIdOf(&meta)
+			if _, ok := counted[etcdContentPrefix+contentId]; !ok {
+				refs[contentId]++
+			}
+			if key := etcdHashKey(&meta); key != "" {
+				if _, ok := indexed[key]; !ok {
+					value, err := json.Marshal(meta)
+					if err != nil {
+						return fmt.Errorf("encode metadata failed: %v", err)
+					}
+					ops = append(ops, clientv3.OpPut(key, string(value)))
+				}
+			}
+		}
+		for contentId, n := range refs {
+			ops = append(ops, clientv3.OpPut(etcdContentPrefix+contentId, strconv.Itoa(n)))
+		}
+
+		unchanged := clientv3.Compare(clientv3.ModRevision(etcdVideoPrefix), "<", rev+1).WithPrefix()
+		done := true
+		for batch := range slices.Chunk(ops, etcdTxnOps) {
+			resp, err := s.client.Txn(ctx).If(unchanged).Then(batch...).Commit()
+			if err != nil {
+				return fmt.Errorf("backfill metadata failed: %v", err)
+			}
+			if !resp.Succeeded {
+				done = false
+				break
+			}
+		}
+		if done {
+			return nil
+		}
+		// A video was created or deleted meanwhile. The batches written so
+		// far are still correct, so they are skipped on the next pass.
+	}
+}
+
+// keys returns the set of keys under prefix.
+func (s *EtcdVideoMetadataService) keys(ctx context.Context, prefix string) (map[string]struct{}, error) {
+	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, fmt.Errorf("list keys failed: %v", err)
+	}
+	keys := make(map[string]struct{}, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys[string(kv.Key)] = struct{}{}
+	}
+	return keys, nil
+}
+
+// etcdHashKey returns the content hash index key of meta, or "" if it has no
+// content hash.
+func etcdHashKey(meta *VideoMetadata) string {
+	if meta.ContentHash == "" {
+		return ""
+	}
+	return etcdHashPrefix + meta.ContentHash + "/" + meta.Id
+}
+
+func (s *EtcdVideoMetadataService) Close() error {
+	return s.client.Close()
+}
+
+// Create stores meta unless the ID is taken, counts it as a reference to its
+// content and indexes it by content hash. The checks and writes are one
+// transaction, retried if the count changes meanwhile, so concurrent web
+// servers cannot create the same ID or share content that is being deleted.
+func (s *EtcdVideoMetadataService) Create(meta VideoMetadata) error {
+	value, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("encode metadata failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	key := etcdVideoPrefix + meta.Id
+	contentId := contentIdOf(&meta)
+	for {
+		refs, stored, cmps, err := s.contentRefs(ctx, contentId)
+		if err != nil {
+			return err
+		}
+		if stored && refs == 0 {
+			return fmt.Errorf("insert metadata failed: %w", errContentDeleted)
+		}
+
+		ops := []clientv3.Op{
+			clientv3.OpPut(key, string(value)),
+			clientv3.OpPut(etcdContentPrefix+contentId, strconv.Itoa(refs+1)),
+		}
+		if hashKey := etcdHashKey(&meta); hashKey != "" {
+			ops = append(ops, clientv3.OpPut(hashKey, string(value)))
+		}
+		resp, err := s.client.Txn(ctx).
+			If(append(cmps, clientv3.Compare(clientv3.CreateRevision(key), "=", 0))...).
+			Then(ops...).
+			Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("insert metadata failed: %v", err)
+		}
+		if resp.Succeeded {
+			return nil
+		}
+		if resp.Responses[0].GetResponseRange().Count > 0 {
+			return fmt.Errorf("insert metadata failed: %w", errVideoExists)
+		}
+		// The count changed meanwhile.
+	}
+}
+
+func (s *EtcdVideoMetadataService) Read(videoId string) (*VideoMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	resp, err := s.client.Get(ctx, etcdVideoPrefix+videoId)
+	if err != nil {
+		return nil, fmt.Errorf("get metadata failed: %v", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrVideoNotFound
+	}
+
+	var meta VideoMetadata
+	if err := json.Unmarshal(resp.Kvs[0].Value, &meta); err != nil {
+		return nil, fmt.Errorf("decode metadata of %v failed: %v", videoId, err)
+	}
+	return &meta, nil
+}
+
+// Delete removes the video, its reference to its content and its index key in
+// one transaction, retried if either changes meanwhile.
+func (s *EtcdVideoMetadataService) Delete(videoId string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	key := etcdVideoPrefix + videoId
+	for {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return "", fmt.Errorf("delete metadata failed: %v", err)
+		}
+		if len(resp.Kvs) == 0 {
+			return "", nil
+		}
+		var meta VideoMetadata
+		if err := json.Unmarshal(resp.Kvs[0].Value, &meta); err != nil {
+			return "", fmt.Errorf("decode metadata of %v failed: %v", videoId, err)
+		}
+
+		contentId := contentIdOf(&meta)
+		refs, _, cmps, err := s.contentRefs(ctx, contentId)
+		if err != nil {
+			return "", err
+		}
+		ops := []clientv3.Op{
+			clientv3.OpDelete(key),
+			clientv3.OpPut(etcdContentPrefix+contentId, strconv.Itoa(max(refs-1, 0))),
+		}
+		if hashKey := etcdHashKey(&meta); hashKey != "" {
+			ops = append(ops, clientv3.OpDelete(hashKey))
+		}
+		txn, err := s.client.Txn(ctx).
+			If(append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision))...).
+			Then(ops...).
+			Commit()
+		if err != nil {
+			return "", fmt.Errorf("delete metadata failed: %v", err)
+		}
+		if !txn.Succeeded {
+			continue
+		}
+		if refs != 1 {
+			return "", nil
+		}
+		return contentId, nil
+	}
+}
+
+// contentRefs returns the number of videos referencing contentId, whether that
+// number is stored, and the comparisons that hold until it changes. Content
+// that no video referenced yet has no stored number.
+func (s *EtcdVideoMetadataService) contentRefs(ctx context.Context, contentId string) (int, bool, []clientv3.Cmp, error) {
+	key := etcdContentPrefix + contentId
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return 0, false, nil, fmt.Errorf("get content references failed: %v", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, false, []clientv3.Cmp{clientv3.Compare(clientv3.CreateRevision(key), "=", 0)}, nil
+	}
+	kv := resp.Kvs[0]
+	refs, err := strconv.Atoi(string(kv.Value))
+	if err != nil {
+		return 0, false, nil, fmt.Errorf("decode references of %v failed: %v", contentId, err)
+	}
+	return refs, true, []clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)}, nil
+}
+
+// List reads every video and filters, orders and pages them in memory, since
+// etcd only orders by key.
+func (s *EtcdVideoMetadataService) List(query VideoQuery) (*VideoPage, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+	c, err := decodeCursor(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	all, _, err := s.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.ToLower(query.Text)
+	var videos []VideoMetadata
+	for _, meta := range all {
+		if text == "" ||
+			strings.Contains(strings.ToLower(meta.Id), text) ||
+			strings.Contains(strings.ToLower(meta.Title), text) ||
+			strings.Contains(strings.ToLower(meta.Description), text) {
+			videos = append(videos, meta)
+		}
+	}
+
+	// Pages after a cursor continue in the query's order; pages before it
+	// are taken in reverse order starting at the cursor.
+	ascending := query.Ascending
+	if c != nil && c.Backward {
+		ascending = !ascending
+	}
+	compare := func(aValue any, aId string, bValue any, bId string) int {
+		n := cmp.Or(compareSortValues(aValue, bValue), cmp.Compare(aId, bId))
+		if !ascending {
+			n = -n
+		}
+		return n
+	}
+	slices.SortFunc(videos, func(a, b VideoMetadata) int {
+		return compare(etcdSortValue(&a, query.SortBy), a.Id, etcdSortValue(&b, query.SortBy), b.Id)
+	})
+	if c != nil {
+		start := sort.Search(len(videos), func(i int) bool {
+			return compare(etcdSortValue(&videos[i], query.SortBy), videos[i].Id, c.Value, c.Id) > 0
+		})
+		videos = videos[start:]
+	}
+	if query.Limit > 0 && len(videos) > query.Limit+1 {
+		videos = videos[:query.Limit+1]
+	}
+
+	keys := make([]any, len(videos))
+	for i := range videos {
+		keys[i] = etcdSortValue(&videos[i], query.SortBy)
+	}
+	return pageOf(query, c, videos, keys), nil
+}
+
+// all returns the metadata of every video and the revision it was read at.
+func (s *EtcdVideoMetadataService) all(ctx context.Context) ([]VideoMetadata, int64, error) {
+	resp, err := s.client.Get(ctx, etcdVideoPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, fmt.Errorf("list metadata failed: %v", err)
+	}
+
+	videos := make([]VideoMetadata, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var meta VideoMetadata
+		if err := json.Unmarshal(kv.Value, &meta); err != nil {
+			return nil, 0, fmt.Errorf("decode metadata of %s failed: %v", kv.Key, err)
+		}
+		videos = append(videos, meta)
+	}
+	return videos, resp.Header.Revision, nil
+}
+
+func (s *EtcdVideoMetadataService) FindByContentHash(hash string) (*VideoMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	resp, err := s.client.Get(ctx, etcdHashPrefix+hash+"/", clientv3.WithPrefix(), clientv3.WithLimit(1))
+	if err != nil {
+		return nil, fmt.Errorf("get metadata by content hash failed: %v", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrVideoNotFound
+	}
+
+	var meta VideoMetadata
+	if err := json.Unmarshal(resp.Kvs[0].Value, &meta); err != nil {
+		return nil, fmt.Errorf("decode metadata of %s failed: %v", resp.Kvs[0].Key, err)
+	}
+	return &meta, nil
+}
+
+// etcdSortValue returns the value meta is ordered by under sortBy, matching
+// the order of SQLiteVideoMetadataService: titles compare with ASCII letters
+// folded to lower case, and untitled videos sort by their ID.
+func etcdSortValue(meta *VideoMetadata, sortBy string) any {
+	switch sortBy {
+	case SortByTitle:
+		title := meta.Title
+		if title == "" {
+			title = meta.Id
+		}
+		return strings.Map(func(r rune) rune {
+			if 'A' <= r && r <= 'Z' {
+				return r + ('a' - 'A')
+			}
+			return r
+		}, title)
+	case SortByDuration:
+		return meta.Duration.Seconds()
+	default:
+		return meta.UploadedAt.UTC().Format(time.RFC3339)
+	}
+}
+
+// compareSortValues compares two sort values of the same field, which are
+// either strings or numbers once decoded from a cursor.
+func compareSortValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		b, _ := b.(string)
+		return cmp.Compare(a, b)
+	case float64:
+		b, _ := b.(float64)
+		return cmp.Compare(a, b)
+	}
+	return 0
+}
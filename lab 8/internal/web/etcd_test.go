@@ -0,0 +1,379 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// freeURL returns an http URL on a free local port.
+func freeURL(t *testing.T) url.URL {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer lis.Close()
+	return url.URL{Scheme: "http", Host: lis.Addr().String()}
+}
+
+// newTestEtcdService starts an embedded single-node etcd server and returns a
+// service connected to it.
+func newTestEtcdService(t *testing.T) *EtcdVideoMetadataService {
+	t.Helper()
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "fatal"
+	clientURL, peerURL := freeURL(t), freeURL(t)
+	cfg.ListenClientUrls = []url.URL{clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{clientURL}
+	cfg.ListenPeerUrls = []url.URL{peerURL}
+	cfg.AdvertisePeerUrls = []url.URL{peerURL}
+	cfg.InitialCluster = cfg.Name + "=" + peerURL.String()
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("start etcd: %v", err)
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatalf("etcd did not become ready")
+	}
+
+	s, err := NewEtcdVideoMetadataService([]string{clientURL.String()})
+	if err != nil {
+		t.Fatalf("NewEtcdVideoMetadataService: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestEtcdCreateRejectsDuplicateId(t *testing.T) {
+	s := newTestEtcdService(t)
+
+	meta := VideoMetadata{Id: "intro", UploadedAt: time.Now().UTC().Truncate(time.Second)}
+	if err := s.Create(meta); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.Create(meta); !errors.Is(err, errVideoExists) {
+		t.Fatalf("second Create error = %v, want errVideoExists", err)
+	}
+
+	// Of concurrent creates of one ID, exactly one succeeds.
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	created := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Create(VideoMetadata{Id: "race", Title: fmt.Sprint(i)})
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err == nil {
+				created++
+			} else if !errors.Is(err, errVideoExists) {
+				t.Errorf("Create: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Errorf("%d concurrent creates succeeded, want 1", created)
+	}
+}
+
+func TestEtcdReadMissingVideo(t *testing.T) {
+	s := newTestEtcdService(t)
+
+	if _, err := s.Read("missing"); !errors.Is(err, ErrVideoNotFound) {
+		t.Fatalf("Read error = %v, want ErrVideoNotFound", err)
+	}
+
+	meta := VideoMetadata{Id: "gone", UploadedAt: time.Now().UTC().Truncate(time.Second), Title: "Gone"}
+	if err := s.Create(meta); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := s.Read("gone")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got.Title != meta.Title || !got.UploadedAt.Equal(meta.UploadedAt) {
+		t.Errorf("Read = %+v, want %+v", got, meta)
+	}
+	if _, err := s.Delete("gone"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Read("gone"); !errors.Is(err, ErrVideoNotFound) {
+		t.Fatalf("Read after Delete error = %v, want ErrVideoNotFound", err)
+	}
+}
+
+// listPages returns the IDs of each page of query, paging forward from the
+// first page and then backward from the last one.
+func listPages(t *testing.T, s VideoMetadataService, query VideoQuery) (forward, backward [][]string) {
+	t.Helper()
+	ids := func(page *VideoPage) []string {
+		var ids []string
+		for _, meta := range page.Videos {
+			ids = append(ids, meta.Id)
+		}
+		return ids
+	}
+
+	var last *VideoPage
+	for {
+		page, err := s.List(query)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", query, err)
+		}
+		forward = append(forward, ids(page))
+		last = page
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	for page := last; page.PrevCursor != ""; {
+		query.Cursor = page.PrevCursor
+		prev, err := s.List(query)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", query, err)
+		}
+		backward = append([][]string{ids(prev)}, backward...)
+		page = prev
+	}
+	return forward, backward
+}
+
+func TestEtcdListMatchesSQLite(t *testing.T) {
+	etcd := newTestEtcdService(t)
+	sqlite, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+
+	// Titles differ in case, some videos are untitled, and durations and
+	// upload times tie so that the ID decides.
+	base := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	videos := []VideoMetadata{
+		{Id: "a-lecture", Title: "lecture 1", Description: "Intro to caching"},
+		{Id: "b-lecture", Title: "Lecture 2", Description: "Consistent hashing"},
+		{Id: "c-demo", Title: "", Description: "Untitled demo"},
+		{Id: "d-cats", Title: "Cats_100%", Description: "not a lecture"},
+		{Id: "e-demo", Title: "Demo", Description: ""},
+		{Id: "f-lecture", Title: "LECTURE 3", Description: "Replication"},
+		{Id: "g-raft", Title: "", Description: "Raft walkthrough"},
+	}
+	for i := range videos {
+		videos[i].UploadedAt = base.Add(time.Duration(i/2) * time.Hour)
+		videos[i].Duration = time.Duration((float64(i%3) + 0.1234567) * float64(time.Second))
+		for _, s := range []VideoMetadataService{etcd, sqlite} {
+			if err := s.Create(videos[i]); err != nil {
+				t.Fatalf("Create %v: %v", videos[i].Id, err)
+			}
+		}
+	}
+
+	for _, text := range []string{"", "lecture", "100%", "DEMO", "nothing"} {
+		for _, sortBy := range []string{SortByUploadedAt, SortByTitle, SortByDuration} {
+			for _, ascending := range []bool{false, true} {
+				query := VideoQuery{Text: text, SortBy: sortBy, Ascending: ascending, Limit: 2}
+				name := fmt.Sprintf("%q by %v ascending=%v", text, sortBy, ascending)
+
+				wantForward, wantBackward := listPages(t, sqlite, query)
+				gotForward, gotBackward := listPages(t, etcd, query)
+				if !slices.EqualFunc(gotForward, wantForward, slices.Equal) {
+					t.Errorf("%v: pages forward = %v, want %v", name, gotForward, wantForward)
+				}
+				if !slices.EqualFunc(gotBackward, wantBackward, slices.Equal) {
+					t.Errorf("%v: pages backward = %v, want %v", name, gotBackward, wantBackward)
+				}
+			}
+		}
+	}
+
+	if _, err := etcd.List(VideoQuery{SortBy: "views"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("List with unknown sort error = %v, want ErrInvalidQuery", err)
+	}
+	if _, err := etcd.List(VideoQuery{SortBy: SortByTitle, Cursor: "bogus"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("List with malformed cursor error = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestContentRefs(t *testing.T) {
+	sqlite, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	services := map[string]VideoMetadataService{
+		"etcd":   newTestEtcdService(t),
+		"sqlite": sqlite,
+	}
+	for name, s := range services {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []string{"a", "b"} {
+				if err := s.Create(VideoMetadata{Id: id, ContentId: "shared"}); err != nil {
+					t.Fatalf("Create %v: %v", id, err)
+				}
+			}
+			if contentId, err := s.Delete("a"); err != nil || contentId != "" {
+				t.Fatalf("Delete a = %q, %v, want no content to delete", contentId, err)
+			}
+			if contentId, err := s.Delete("b"); err != nil || contentId != "shared" {
+				t.Fatalf("Delete b = %q, %v, want shared", contentId, err)
+			}
+			if contentId, err := s.Delete("b"); err != nil || contentId != "" {
+				t.Fatalf("Delete b again = %q, %v, want no content to delete", contentId, err)
+			}
+
+			// Deleted content cannot be shared again.
+			if err := s.Create(VideoMetadata{Id: "c", ContentId: "shared"}); !errors.Is(err, errContentDeleted) {
+				t.Fatalf("Create sharing deleted content error = %v, want errContentDeleted", err)
+			}
+			if _, err := s.Read("c"); !errors.Is(err, ErrVideoNotFound) {
+				t.Fatalf("Read c error = %v, want ErrVideoNotFound", err)
+			}
+
+			// Of concurrent deletes of the videos sharing content, exactly
+			// one deletes the content.
+			const n = 8
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := s.Create(VideoMetadata{Id: fmt.Sprint("busy", i), ContentId: "busy"}); err != nil {
+						t.Errorf("Create: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			deleted := make(chan string, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					contentId, err := s.Delete(fmt.Sprint("busy", i))
+					if err != nil {
+						t.Errorf("Delete: %v", err)
+					}
+					deleted <- contentId
+				}()
+			}
+			wg.Wait()
+			close(deleted)
+			var contentIds []string
+			for contentId := range deleted {
+				if contentId != "" {
+					contentIds = append(contentIds, contentId)
+				}
+			}
+			if !slices.Equal(contentIds, []string{"busy"}) {
+				t.Errorf("concurrent deletes returned content %v, want [busy] once", contentIds)
+			}
+		})
+	}
+}
+
+// TestEtcdBackfillsLegacyVideos starts a service on videos stored before
+// references were counted and videos were indexed by content hash.
+func TestEtcdBackfillsLegacyVideos(t *testing.T) {
+	s := newTestEtcdService(t)
+
+	for _, id := range []string{"old1", "old2"} {
+		value := fmt.Sprintf(`{"Id":%q,"ContentId":"legacy","ContentHash":"cafe"}`, id)
+		if _, err := s.client.Put(context.Background(), etcdVideoPrefix+id, value); err != nil {
+			t.Fatalf("put %v: %v", id, err)
+		}
+	}
+	s, err := NewEtcdVideoMetadataService(s.client.Endpoints())
+	if err != nil {
+		t.Fatalf("NewEtcdVideoMetadataService: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if meta, err := s.FindByContentHash("cafe"); err != nil || contentIdOf(meta) != "legacy" {
+		t.Fatalf("FindByContentHash = %+v, %v, want a video of legacy", meta, err)
+	}
+	if contentId, err := s.Delete("old1"); err != nil || contentId != "" {
+		t.Fatalf("Delete old1 = %q, %v, want no content to delete", contentId, err)
+	}
+	if err := s.Create(VideoMetadata{Id: "new", ContentId: "legacy", ContentHash: "cafe"}); err != nil {
+		t.Fatalf("Create sharing legacy content: %v", err)
+	}
+	if contentId, err := s.Delete("old2"); err != nil || contentId != "" {
+		t.Fatalf("Delete old2 = %q, %v, want no content to delete", contentId, err)
+	}
+	if contentId, err := s.Delete("new"); err != nil || contentId != "legacy" {
+		t.Fatalf("Delete new = %q, %v, want legacy", contentId, err)
+	}
+	if _, err := s.FindByContentHash("cafe"); !errors.Is(err, ErrVideoNotFound) {
+		t.Fatalf("FindByContentHash after deleting every video error = %v, want ErrVideoNotFound", err)
+	}
+}
+
+func TestFindByContentHash(t *testing.T) {
+	sqlite, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	services := map[string]VideoMetadataService{
+		"etcd":   newTestEtcdService(t),
+		"sqlite": sqlite,
+	}
+	for name, s := range services {
+		t.Run(name, func(t *testing.T) {
+			videos := []VideoMetadata{
+				{Id: "a", ContentId: "content-a", ContentHash: "aaaa"},
+				{Id: "b", ContentId: "content-a", ContentHash: "aaaa"},
+				{Id: "c", ContentId: "content-c", ContentHash: "aaaa0"},
+				{Id: "d"},
+			}
+			for _, meta := range videos {
+				if err := s.Create(meta); err != nil {
+					t.Fatalf("Create %v: %v", meta.Id, err)
+				}
+			}
+
+			// A hash that another hash starts with only finds its own videos.
+			for _, hash := range []string{"aaaa", "aaaa0"} {
+				meta, err := s.FindByContentHash(hash)
+				if err != nil {
+					t.Fatalf("FindByContentHash %v: %v", hash, err)
+				}
+				if meta.ContentHash != hash {
+					t.Errorf("FindByContentHash %v = video %v with hash %v", hash, meta.Id, meta.ContentHash)
+				}
+			}
+			if _, err := s.FindByContentHash("missing"); !errors.Is(err, ErrVideoNotFound) {
+				t.Errorf("FindByContentHash missing error = %v, want ErrVideoNotFound", err)
+			}
+
+			// The hash is found until its last video is deleted.
+			if _, err := s.Delete("a"); err != nil {
+				t.Fatalf("Delete a: %v", err)
+			}
+			if meta, err := s.FindByContentHash("aaaa"); err != nil || meta.Id != "b" {
+				t.Errorf("FindByContentHash after deleting a = %+v, %v, want b", meta, err)
+			}
+			if _, err := s.Delete("b"); err != nil {
+				t.Fatalf("Delete b: %v", err)
+			}
+			if _, err := s.FindByContentHash("aaaa"); !errors.Is(err, ErrVideoNotFound) {
+				t.Errorf("FindByContentHash after deleting b error = %v, want ErrVideoNotFound", err)
+			}
+		})
+	}
+}
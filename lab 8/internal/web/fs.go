@@ -3,7 +3,9 @@
 package web
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -15,6 +17,7 @@ type FSVideoContentService struct {
 
 // Uncomment the following line to ensure FSVideoContentService implements VideoContentService
 var _ VideoContentService = (*FSVideoContentService)(nil)
+var _ StreamingVideoContentService = (*FSVideoContentService)(nil)
 
 func NewFSVideoContentService(baseDir string) (*FSVideoContentService, error) {
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
@@ -40,8 +43,77 @@ func (s *FSVideoContentService) Read(videoId string, filename string) ([]byte, e
 	videoDir := filepath.Join(s.baseDir, videoId)
 	filePath := filepath.Join(videoDir, filename)
 	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read content file %v: %w", filename, ErrContentNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read content file %v: %v", filename, err)
 	}
 	return data, nil
 }
+
+func (s *FSVideoContentService) Open(videoId string, filename string) (io.ReadCloser, int64, error) {
+	filePath := filepath.Join(s.baseDir, videoId, filename)
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, fmt.Errorf("failed to read content file %v: %w", filename, ErrContentNotFound)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read content file %v: %v", filename, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("failed to read content file %v: %v", filename, err)
+	}
+	return f, info.Size(), nil
+}
+
+// Create writes the file to a hidden temporary file that Close renames into
+// place, so readers never see a partial file.
+func (s *FSVideoContentService) Create(videoId string, filename string) (io.WriteCloser, error) {
+	videoDir := filepath.Join(s.baseDir, videoId)
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create content directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(videoDir, "."+filename+".*.part")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content file %s: %w", filename, err)
+	}
+	return &fsContentWriter{File: tmp, path: filepath.Join(videoDir, filename)}, nil
+}
+
+type fsContentWriter struct {
+	*os.File
+	path string
+}
+
+func (w *fsContentWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.Name())
+		return fmt.Errorf("failed to write content file %s: %w", filepath.Base(w.path), err)
+	}
+	if err := os.Chmod(w.Name(), 0644); err != nil {
+		os.Remove(w.Name())
+		return fmt.Errorf("failed to write content file %s: %w", filepath.Base(w.path), err)
+	}
+	if err := os.Rename(w.Name(), w.path); err != nil {
+		os.Remove(w.Name())
+		return fmt.Errorf("failed to write content file %s: %w", filepath.Base(w.path), err)
+	}
+	return nil
+}
+
+func (w *fsContentWriter) CloseWithError(err error) error {
+	w.File.Close()
+	os.Remove(w.Name())
+	return nil
+}
+
+func (s *FSVideoContentService) Delete(videoId string) error {
+	videoDir := filepath.Join(s.baseDir, videoId)
+	if err := os.RemoveAll(videoDir); err != nil {
+		return fmt.Errorf("failed to delete content of %v: %v", videoId, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"log"
+	"maps"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Health states reported by ListNodes. A node becomes suspect after a failed
+// probe and down after failThreshold consecutive failures. A down node is up
+// again after recoverThreshold consecutive successful probes.
+const (
+	healthUp      = "up"
+	healthSuspect = "suspect"
+	healthDown    = "down"
+)
+
+type nodeHealth struct {
+	state     string
+	lastSeen  time.Time
+	failures  int
+	successes int
+}
+
+// record updates the node's state with the result of a probe.
+func (h *nodeHealth) record(ok bool, now time.Time, failThreshold, recoverThreshold int) {
+	if ok {
+		h.lastSeen = now
+		h.failures = 0
+		h.successes++
+		if h.state != healthDown || h.successes >= recoverThreshold {
+			h.state = healthUp
+		}
+		return
+	}
+
+	h.successes = 0
+	h.failures++
+	if h.failures >= failThreshold {
+		h.state = healthDown
+	} else if h.state == healthUp {
+		h.state = healthSuspect
+	}
+}
+
+// StartHealthChecker probes every storage node each interval in the
+// background until ctx is cancelled.
+func (s *NetworkVideoContentService) StartHealthChecker(ctx context.Context, interval time.Duration, failThreshold, recoverThreshold int) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.probeNodes(ctx, interval, failThreshold, recoverThreshold)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// probeNodes runs one health check against every node concurrently.
+func (s *NetworkVideoContentService) probeNodes(ctx context.Context, timeout time.Duration, failThreshold, recoverThreshold int) {
+	s.mutex.RLock()
+	conns := maps.Clone(s.conns)
+	s.mutex.RUnlock()
+
+	type result struct {
+		addr string
+		ok   bool
+	}
+	results := make(chan result, len(conns))
+	for addr, conn := range conns {
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			results <- result{addr, err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING}
+		}()
+	}
+
+	now := time.Now()
+	for range conns {
+		r := <-results
+		s.mutex.Lock()
+		if h, ok := s.health[r.addr]; ok {
+			prev := h.state
+			h.record(r.ok, now, failThreshold, recoverThreshold)
+			if h.state != prev {
+				log.Printf("Storage node %v is %v", r.addr, h.state)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// isDown reports whether node failed its recent health probes. The caller must
+// hold s.mutex.
+func (s *NetworkVideoContentService) isDown(node string) bool {
+	h, ok := s.health[node]
+	return ok && h.state == healthDown
+}
@@ -1,19 +1,90 @@
 package web
 
-import "time"
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrVideoNotFound is returned by VideoMetadataService.Read for an unknown
+// video.
+var ErrVideoNotFound = errors.New("video not found")
+
+// ErrContentNotFound is returned by VideoContentService.Read and
+// StreamingVideoContentService.Open for a file that does not exist.
+var ErrContentNotFound = errors.New("content not found")
+
+// errContentDeleted is returned by VideoMetadataService.Create for a video
+// sharing content whose last video was deleted.
+var errContentDeleted = errors.New("content was deleted")
 
 type VideoMetadata struct {
 	Id         string
 	UploadedAt time.Time
+
+	// Supplied by the uploader.
+	Title       string
+	Description string
+
+	// Extracted from the uploaded file with ffprobe.
+	Duration         time.Duration
+	Width            int
+	Height           int
+	VideoCodec       string
+	AudioCodec       string
+	FrameRate        float64
+	OriginalSize     int64
+	OriginalFilename string
+
+	// ContentHash is the hex SHA-256 of the uploaded file, and ContentId the
+	// ID its transcoded files are stored under in the VideoContentService.
+	// Byte-identical uploads share their content.
+	ContentHash string
+	ContentId   string
+}
+
+// contentIdOf returns the ID the content of meta is stored under. Videos
+// created before deduplication have none and use their own ID.
+func contentIdOf(meta *VideoMetadata) string {
+	if meta.ContentId == "" {
+		return meta.Id
+	}
+	return meta.ContentId
 }
 
 type VideoMetadataService interface {
 	Read(id string) (*VideoMetadata, error)
-	List() ([]VideoMetadata, error)
-	Create(videoId string, uploadedAt time.Time) error
+	// List returns the page of videos selected by query.
+	List(query VideoQuery) (*VideoPage, error)
+	// Create stores the metadata of a new video and counts it as a reference
+	// to its content, in one step. Content whose references all went away
+	// cannot be referenced again, and Create fails with errContentDeleted.
+	Create(meta VideoMetadata) error
+	// Delete removes the video's metadata and its reference to its content, in
+	// one step. If that was the last reference, it returns the content ID for
+	// the caller to delete the content. Deleting a missing video is not an
+	// error.
+	Delete(videoId string) (string, error)
+	// FindByContentHash returns a video uploaded with the given content hash,
+	// or ErrVideoNotFound if there is none.
+	FindByContentHash(hash string) (*VideoMetadata, error)
 }
 
 type VideoContentService interface {
 	Read(videoId string, filename string) ([]byte, error)
 	Write(videoId string, filename string, data []byte) error
+	// Delete removes every file of the video. Deleting a missing video is not
+	// an error.
+	Delete(videoId string) error
+}
+
+// StreamingVideoContentService is a VideoContentService that can transfer
+// files without holding them in memory.
+type StreamingVideoContentService interface {
+	VideoContentService
+	// Open returns a reader of the file and its size in bytes.
+	Open(videoId string, filename string) (io.ReadCloser, int64, error)
+	// Create returns a writer of the file, which is stored once Close returns
+	// nil. Closing it with CloseWithError instead discards what was written.
+	Create(videoId string, filename string) (io.WriteCloser, error)
 }
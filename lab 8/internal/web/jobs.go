@@ -0,0 +1,169 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Transcoding job states.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobFailed  = "failed"
+	jobDone    = "done"
+)
+
+// jobRetention is how long finished jobs remain visible.
+const jobRetention = time.Hour
+
+var errQueueFull = errors.New("transcoding queue is full")
+
+// transcodeJob is an upload waiting for or going through transcoding.
+type transcodeJob struct {
+	Id         string    `json:"id"`
+	VideoId    string    `json:"video_id"`
+	State      string    `json:"state"`
+	Percent    float64   `json:"percent"`
+	Error      string    `json:"error,omitempty"`
+	LogTail    string    `json:"log_tail,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+
+	// inputPath is the uploaded file, removed once the job finishes.
+	inputPath string
+	// meta holds the metadata known at upload time, which is completed from
+	// the transcoded file.
+	meta VideoMetadata
+}
+
+// jobQueue runs transcoding jobs on a fixed number of workers. At most
+// queueSize jobs wait for a worker; further uploads are rejected.
+type jobQueue struct {
+	mutex   sync.Mutex
+	jobs    map[string]*transcodeJob
+	queue   chan *transcodeJob
+	workers int
+	run     func(job *transcodeJob) error
+}
+
+func newJobQueue(workers int, queueSize int, run func(job *transcodeJob) error) *jobQueue {
+	return &jobQueue{
+		jobs:    make(map[string]*transcodeJob),
+		queue:   make(chan *transcodeJob, queueSize),
+		workers: workers,
+		run:     run,
+	}
+}
+
+// start launches the workers.
+func (q *jobQueue) start() {
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			for job := range q.queue {
+				q.update(job.Id, func(j *transcodeJob) { j.State = jobRunning })
+				err := q.run(job)
+				q.update(job.Id, func(j *transcodeJob) {
+					j.FinishedAt = time.Now()
+					if err != nil {
+						j.State = jobFailed
+						j.Error = err.Error()
+						return
+					}
+					j.State = jobDone
+					j.Percent = 100
+				})
+			}
+		}()
+	}
+}
+
+func newJobId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// enqueue adds a job for the video described by meta. It fails if the queue is
+// full or a job for the same video is still queued or running.
+func (q *jobQueue) enqueue(meta VideoMetadata, inputPath string) (*transcodeJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.prune()
+	for _, j := range q.jobs {
+		if j.VideoId == meta.Id && (j.State == jobQueued || j.State == jobRunning) {
+			return nil, errVideoExists
+		}
+	}
+
+	job := &transcodeJob{
+		Id:        newJobId(),
+		VideoId:   meta.Id,
+		State:     jobQueued,
+		CreatedAt: time.Now(),
+		inputPath: inputPath,
+		meta:      meta,
+	}
+	select {
+	case q.queue <- job:
+	default:
+		return nil, errQueueFull
+	}
+	q.jobs[job.Id] = job
+	copied := *job
+	return &copied, nil
+}
+
+// prune forgets jobs that finished more than jobRetention ago. The caller must
+// hold q.mutex.
+func (q *jobQueue) prune() {
+	for id, j := range q.jobs {
+		if !j.FinishedAt.IsZero() && time.Since(j.FinishedAt) > jobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// update applies fn to the job with the given id.
+func (q *jobQueue) update(id string, fn func(j *transcodeJob)) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if j, ok := q.jobs[id]; ok {
+		fn(j)
+	}
+}
+
+// get returns a copy of the job with the given id.
+func (q *jobQueue) get(id string) (*transcodeJob, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *j
+	return &copied, true
+}
+
+// pending returns copies of the jobs that are not done yet, oldest first.
+func (q *jobQueue) pending() []transcodeJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var jobs []transcodeJob
+	for _, j := range q.jobs {
+		if j.State != jobDone {
+			jobs = append(jobs, *j)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+	})
+	return jobs
+}
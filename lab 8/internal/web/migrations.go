@@ -0,0 +1,195 @@
+package web
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFS holds the SQLite schema migrations. Each file is named
+// NNNN_description.sql, where NNNN is the schema version it migrates to.
+// Versions start at 1 and have no gaps.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations returns the embedded migrations ordered by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations failed: %v", err)
+	}
+
+	var migrations []migration
+	for _, ent := range entries {
+		prefix, _, ok := strings.Cut(ent.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration name %v", ent.Name())
+		}
+		data, err := migrationFS.ReadFile(path.Join("migrations", ent.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %v failed: %v", ent.Name(), err)
+		}
+		migrations = append(migrations, migration{version, ent.Name(), string(data)})
+	}
+	slices.SortFunc(migrations, func(a, b migration) int { return a.version - b.version })
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %v out of sequence, want version %d", m.name, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// migrate brings the schema of db up to date, applying each pending migration
+// in its own transaction together with the record of its version. It fails
+// if the schema is newer than the latest migration.
+func migrate(db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	latest := len(migrations)
+
+	createTable := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createTable); err != nil {
+		return fmt.Errorf("create migrations table failed: %v", err)
+	}
+	if err := recordLegacyVersion(db); err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than the latest supported version %d", current, latest)
+	}
+
+	for _, m := range migrations[current:] {
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func schemaVersion(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}) (int, error) {
+	var version int
+	if err := q.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations;`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version failed: %v", err)
+	}
+	return version, nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin migration %v failed: %v", m.name, err)
+	}
+	defer tx.Rollback()
+
+	// Another process may have applied it since the version was read.
+	current, err := schemaVersion(tx)
+	if err != nil {
+		return err
+	}
+	if current >= m.version {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return fmt.Errorf("apply migration %v failed: %v", m.name, err)
+	}
+	insert := `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?);`
+	if _, err := tx.Exec(insert, m.version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("record migration %v failed: %v", m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration %v failed: %v", m.name, err)
+	}
+	return nil
+}
+
+// recordLegacyVersion records the schema version of databases created before
+// migrations were tracked, which have a videos table but no recorded version.
+// Those only ever had the schema of migration 1, or that of migration 2.
+func recordLegacyVersion(db *sql.DB) error {
+	current, err := schemaVersion(db)
+	if err != nil || current > 0 {
+		return err
+	}
+	columns, err := tableColumns(db, "videos")
+	if err != nil {
+		return err
+	}
+
+	legacy := 0
+	switch {
+	case columns["original_filename"]:
+		legacy = 2
+	case columns["id"]:
+		legacy = 1
+	}
+	if legacy == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin recording legacy schema version failed: %v", err)
+	}
+	defer tx.Rollback()
+	insert := `INSERT OR IGNORE INTO schema_migrations (version, applied_at) VALUES (?, ?);`
+	for version := 1; version <= legacy; version++ {
+		if _, err := tx.Exec(insert, version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("record legacy schema version failed: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit legacy schema version failed: %v", err)
+	}
+	return nil
+}
+
+// tableColumns returns the names of the columns of table, or none if it does
+// not exist.
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?);`, table)
+	if err != nil {
+		return nil, fmt.Errorf("query table info failed: %v", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan table info failed: %v", err)
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return columns, nil
+}
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS videos (
+	id TEXT PRIMARY KEY,
+	uploaded_at DATETIME NOT NULL
+);
@@ -0,0 +1,10 @@
+ALTER TABLE videos ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN duration_seconds REAL NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN video_codec TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN audio_codec TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN frame_rate REAL NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN original_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN original_filename TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Indexes for the sort orders of VideoMetadataService.List.
+CREATE INDEX videos_uploaded_at ON videos (uploaded_at, id);
+CREATE INDEX videos_duration ON videos (duration_seconds, id);
@@ -0,0 +1,6 @@
+-- Videos created before deduplication store their content under their ID.
+ALTER TABLE videos ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN content_id TEXT NOT NULL DEFAULT '';
+UPDATE videos SET content_id = id;
+CREATE INDEX videos_content_hash ON videos (content_hash);
+CREATE INDEX videos_content_id ON videos (content_id);
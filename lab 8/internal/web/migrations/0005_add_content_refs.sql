@@ -0,0 +1,9 @@
+-- refs counts the videos whose content is stored under content_id. Content
+-- whose last video was deleted keeps its row with refs 0, so that no new
+-- video starts sharing it.
+CREATE TABLE content_refs (
+	content_id TEXT PRIMARY KEY,
+	refs INTEGER NOT NULL
+);
+INSERT INTO content_refs (content_id, refs)
+	SELECT content_id, COUNT(*) FROM videos GROUP BY content_id;
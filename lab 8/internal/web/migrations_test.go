@@ -0,0 +1,159 @@
+package web
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openTestDB opens the SQLite database at path without migrating it.
+func openTestDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open %v: %v", path, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// appliedVersions returns the schema versions recorded in db.
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version;`)
+	if err != nil {
+		t.Fatalf("query schema versions: %v", err)
+	}
+	defer rows.Close()
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatalf("scan schema version: %v", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows iteration error: %v", err)
+	}
+	return versions
+}
+
+// checkAllApplied checks that every migration is recorded in db exactly once.
+func checkAllApplied(t *testing.T, db *sql.DB) {
+	t.Helper()
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	versions := appliedVersions(t, db)
+	if len(versions) != len(migrations) {
+		t.Fatalf("applied versions = %v, want 1 to %d", versions, len(migrations))
+	}
+	for i, version := range versions {
+		if version != i+1 {
+			t.Fatalf("applied versions = %v, want 1 to %d", versions, len(migrations))
+		}
+	}
+}
+
+// TestMigrateLegacyDatabases upgrades databases created before migrations
+// were recorded, which have the schema of migration 1 or 2 and no versions.
+func TestMigrateLegacyDatabases(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		version int
+		insert  string
+	}{
+		{"v1", 1, `INSERT INTO videos (id, uploaded_at) VALUES ('old', '2024-01-02T03:04:05Z');`},
+		{"v2", 2, `INSERT INTO videos (id, uploaded_at, title) VALUES ('old', '2024-01-02T03:04:05Z', 'Old');`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "videos.db")
+			legacy := openTestDB(t, path)
+			for _, m := range migrations[:tt.version] {
+				if _, err := legacy.Exec(m.sql); err != nil {
+					t.Fatalf("apply %v: %v", m.name, err)
+				}
+			}
+			if _, err := legacy.Exec(tt.insert); err != nil {
+				t.Fatalf("insert legacy video: %v", err)
+			}
+			legacy.Close()
+
+			s, err := NewSQLiteVideoMetadataService(path)
+			if err != nil {
+				t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+			}
+			checkAllApplied(t, s.db)
+
+			meta, err := s.Read("old")
+			if err != nil {
+				t.Fatalf("Read legacy video: %v", err)
+			}
+			if contentIdOf(meta) != "old" {
+				t.Errorf("legacy video content ID = %q, want its own ID", contentIdOf(meta))
+			}
+			if tt.version == 2 && meta.Title != "Old" {
+				t.Errorf("legacy video title = %q, want Old", meta.Title)
+			}
+			// The content references were counted when migrating.
+			if contentId, err := s.Delete("old"); err != nil || contentId != "old" {
+				t.Errorf("Delete legacy video = %q, %v, want old", contentId, err)
+			}
+		})
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "videos.db")
+	if _, err := NewSQLiteVideoMetadataService(path); err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+
+	db := openTestDB(t, path)
+	versions := appliedVersions(t, db)
+	newer := versions[len(versions)-1] + 1
+	insert := `INSERT INTO schema_migrations (version, applied_at) VALUES (?, '2030-01-01T00:00:00Z');`
+	if _, err := db.Exec(insert, newer); err != nil {
+		t.Fatalf("record newer version: %v", err)
+	}
+
+	_, err := NewSQLiteVideoMetadataService(path)
+	if err == nil || !strings.Contains(err.Error(), "newer than the latest supported") {
+		t.Fatalf("NewSQLiteVideoMetadataService error = %v, want newer schema error", err)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "videos.db")
+	s, err := NewSQLiteVideoMetadataService(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	if err := s.Create(VideoMetadata{Id: "intro", Title: "Intro"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Migrating again, directly or by reopening, changes nothing.
+	for i := 0; i < 2; i++ {
+		if err := migrate(s.db); err != nil {
+			t.Fatalf("migrate again: %v", err)
+		}
+	}
+	reopened, err := NewSQLiteVideoMetadataService(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	checkAllApplied(t, reopened.db)
+	if meta, err := reopened.Read("intro"); err != nil || meta.Title != "Intro" {
+		t.Errorf("Read after migrating again = %+v, %v, want Intro", meta, err)
+	}
+}
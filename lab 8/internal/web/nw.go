@@ -3,28 +3,51 @@
 package web
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"log"
+	"maps"
 	"net"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"tritontube/internal/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// HashRing places every node on the ring vnodes*weight times so that keys
+// spread evenly across a small number of nodes.
 type HashRing struct {
 	mutex        sync.Mutex
+	vnodes       int
+	weights      map[string]int
 	nodes        map[uint64]string
 	sortedHashes []uint64
 }
 
-func NewHashRing() *HashRing {
-	return &HashRing{nodes: make(map[uint64]string)}
+func NewHashRing(vnodes int) *HashRing {
+	if vnodes < 1 {
+		vnodes = 1
+	}
+	return &HashRing{
+		vnodes:  vnodes,
+		weights: make(map[string]int),
+		nodes:   make(map[uint64]string),
+	}
 }
 
 func hashStringToUint64(s string) uint64 {
@@ -32,13 +55,31 @@ func hashStringToUint64(s string) uint64 {
 	return binary.BigEndian.Uint64(sum[:8])
 }
 
-func (h *HashRing) addNode(addr string) {
+// virtualNodeHash returns the ring position of the i-th virtual node of addr.
+// The first virtual node sits at the hash of the bare address.
+func virtualNodeHash(addr string, i int) uint64 {
+	if i == 0 {
+		return hashStringToUint64(addr)
+	}
+	return hashStringToUint64(fmt.Sprintf("%v#%d", addr, i))
+}
+
+func (h *HashRing) addNode(addr string, weight int) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	hash := hashStringToUint64(addr)
-	h.nodes[hash] = addr
-	h.sortedHashes = append(h.sortedHashes, hash)
+	if weight < 1 {
+		weight = 1
+	}
+	h.weights[addr] = weight
+	for i := 0; i < h.vnodes*weight; i++ {
+		hash := virtualNodeHash(addr, i)
+		if _, taken := h.nodes[hash]; taken {
+			continue
+		}
+		h.nodes[hash] = addr
+		h.sortedHashes = append(h.sortedHashes, hash)
+	}
 	sort.Slice(h.sortedHashes, func(i, j int) bool {
 		return h.sortedHashes[i] < h.sortedHashes[j]
 	})
@@ -48,54 +89,271 @@ func (h *HashRing) removeNode(addr string) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	hash := hashStringToUint64(addr)
-	delete(h.nodes, hash)
-
-	idx := sort.Search(len(h.sortedHashes), func(i int) bool {
-		return h.sortedHashes[i] >= hash
-	})
-	if idx < len(h.sortedHashes) && h.sortedHashes[idx] == hash {
-		h.sortedHashes = append(h.sortedHashes[:idx], h.sortedHashes[idx+1:]...)
+	delete(h.weights, addr)
+	hashes := h.sortedHashes[:0]
+	for _, hash := range h.sortedHashes {
+		if h.nodes[hash] == addr {
+			delete(h.nodes, hash)
+			continue
+		}
+		hashes = append(hashes, hash)
 	}
+	h.sortedHashes = hashes
+}
+
+// weight returns the weight addr was added with, or 0 if it is not in the
+// ring.
+func (h *HashRing) weight(addr string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.weights[addr]
 }
 
-func (h *HashRing) getNode(key string) (string, error) {
+// getNodes returns up to n distinct nodes responsible for key, walking the
+// ring clockwise from the key's hash. The first node is the primary owner.
+func (h *HashRing) getNodes(key string, n int) ([]string, error) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
 	if len(h.nodes) == 0 {
-		return "", fmt.Errorf("no nodes in the hash ring")
+		return nil, fmt.Errorf("no nodes in the hash ring")
 	}
 
 	hash := hashStringToUint64(key)
 	idx := sort.Search(len(h.sortedHashes), func(i int) bool {
 		return h.sortedHashes[i] >= hash
 	})
-	return h.nodes[h.sortedHashes[uint64(idx%len(h.sortedHashes))]], nil
 
+	var owners []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(h.sortedHashes) && len(owners) < n; i++ {
+		node := h.nodes[h.sortedHashes[(idx+i)%len(h.sortedHashes)]]
+		if !seen[node] {
+			seen[node] = true
+			owners = append(owners, node)
+		}
+	}
+	return owners, nil
+}
+
+// clone returns a copy of the ring that can be modified independently.
+func (h *HashRing) clone() *HashRing {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	c := NewHashRing(h.vnodes)
+	for addr, weight := range h.weights {
+		c.weights[addr] = weight
+	}
+	for hash, node := range h.nodes {
+		c.nodes[hash] = node
+	}
+	c.sortedHashes = append([]uint64(nil), h.sortedHashes...)
+	return c
+}
+
+// chunkSize is the amount of file data sent in each StoreFileStream message.
+const chunkSize = 1 << 20
+
+// storeFile streams r to the storage node under key in chunkSize pieces,
+// followed by the SHA-256 of everything sent so the node can verify it.
+func storeFile(ctx context.Context, client proto.VideoContentStorageServiceClient, key string, r io.Reader) (int64, error) {
+	// Cancelling the stream, rather than closing it, makes the storage node
+	// discard the partial file when r fails midway.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.StoreFileStream(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	hash := sha256.New()
+	buf := make([]byte, chunkSize)
+	first := true
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			req := &proto.StoreFileRequest{Data: buf[:n]}
+			if first {
+				req.Key = key
+				first = false
+			}
+			if err := stream.Send(req); err != nil {
+				// The real error is reported by CloseAndRecv.
+				if _, rerr := stream.CloseAndRecv(); rerr != nil {
+					return total, rerr
+				}
+				return total, err
+			}
+			hash.Write(buf[:n])
+			total += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if err := stream.Send(&proto.StoreFileRequest{Key: key, Sha256: hash.Sum(nil)}); err != nil {
+		if _, rerr := stream.CloseAndRecv(); rerr != nil {
+			return total, rerr
+		}
+		return total, err
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// getFile streams key from the storage node into w. It fails after the last
+// chunk if the data does not match the checksum reported by the node.
+func getFile(ctx context.Context, client proto.VideoContentStorageServiceClient, key string, w io.Writer) (int64, error) {
+	r, err := openFile(ctx, client, key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(w, r)
+}
+
+// openFile starts streaming key from the storage node and waits for the
+// first chunk, so that a missing file or unreachable node fails here rather
+// than on the first Read.
+func openFile(ctx context.Context, client proto.VideoContentStorageServiceClient, key string) (*fileReader, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := client.GetFileStream(ctx, &proto.GetFileRequest{Key: key})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	first, err := stream.Recv()
+	if err == io.EOF {
+		err = fmt.Errorf("empty response for %v", key)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	r := &fileReader{
+		key:      key,
+		stream:   stream,
+		cancel:   cancel,
+		size:     first.Size,
+		expected: first.Sha256,
+		hash:     sha256.New(),
+		buf:      first.Data,
+	}
+	r.hash.Write(first.Data)
+	return r, nil
+}
+
+// fileReader reads a file streamed by GetFileStream. It fails at the end of the
+// file if the data does not match the checksum reported by the node.
+type fileReader struct {
+	key      string
+	stream   proto.VideoContentStorageService_GetFileStreamClient
+	cancel   context.CancelFunc
+	size     int64
+	expected []byte
+	hash     hash.Hash
+	// buf is the unread part of the last chunk received.
+	buf []byte
+	err error
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		resp, err := r.stream.Recv()
+		if err == io.EOF {
+			r.err = io.EOF
+			if digest := r.hash.Sum(nil); r.expected != nil && !bytes.Equal(digest, r.expected) {
+				r.err = fmt.Errorf("checksum mismatch for %v: got %x, want %x", r.key, digest, r.expected)
+			}
+			continue
+		}
+		if err != nil {
+			r.err = err
+			continue
+		}
+		r.hash.Write(resp.Data)
+		r.buf = resp.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *fileReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// copyFile streams key from src to dst without buffering the whole file.
+func copyFile(ctx context.Context, src, dst proto.VideoContentStorageServiceClient, key string) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := getFile(ctx, src, key, pw)
+		pw.CloseWithError(err)
+	}()
+
+	n, err := storeFile(ctx, dst, key, pr)
+	pr.CloseWithError(err)
+	return n, err
 }
 
 // NetworkVideoContentService implements VideoContentService using a network of nodes.
+// Every key is stored on the next replicas distinct nodes clockwise on the ring.
+// Membership changes only move the keys whose replica set actually changes.
 type NetworkVideoContentService struct {
 	proto.UnimplementedVideoContentAdminServiceServer
 	mutex    sync.RWMutex
 	hashRing *HashRing
-	nodes    map[string]proto.VideoContentStorageServiceClient
-	conns    map[string]*grpc.ClientConn
-	allKeys  []string
-	allNodes []string
+	// prevRing is the placement before the running rebalance, or nil.
+	prevRing  *HashRing
+	rebalance *rebalance
+	// lastRebalance is the most recently finished rebalance, kept so that
+	// WatchMigration can report its outcome.
+	lastRebalance *rebalance
+	replicas      int
+	nodes         map[string]proto.VideoContentStorageServiceClient
+	conns         map[string]*grpc.ClientConn
+	allKeys       map[string]struct{}
+	allNodes      []string
+	health        map[string]*nodeHealth
 }
 
+// ErrContentUnavailable is returned by Read when none of the nodes holding the
+// content can be reached, and by Create when one of them is down.
+var ErrContentUnavailable = errors.New("content unavailable")
+
 // Uncomment the following line to ensure NetworkVideoContentService implements VideoContentService
 var _ VideoContentService = (*NetworkVideoContentService)(nil)
+var _ StreamingVideoContentService = (*NetworkVideoContentService)(nil)
 
-func NewNetworkVideoContentService() *NetworkVideoContentService {
+func NewNetworkVideoContentService(replicas int, vnodes int) *NetworkVideoContentService {
+	if replicas < 1 {
+		replicas = 1
+	}
 	return &NetworkVideoContentService{
-		hashRing: NewHashRing(),
+		hashRing: NewHashRing(vnodes),
+		replicas: replicas,
 		nodes:    make(map[string]proto.VideoContentStorageServiceClient),
 		conns:    make(map[string]*grpc.ClientConn),
-		allKeys:  []string{},
+		allKeys:  make(map[string]struct{}),
 		allNodes: []string{},
+		health:   make(map[string]*nodeHealth),
 	}
 }
 
@@ -111,136 +369,492 @@ func (s *NetworkVideoContentService) StartAdminServer(addr string) error {
 	return grpcServer.Serve(lis)
 }
 
+// clientsFor returns the storage clients of nodes. The caller must hold s.mutex.
+func (s *NetworkVideoContentService) clientsFor(nodes []string) []proto.VideoContentStorageServiceClient {
+	clients := make([]proto.VideoContentStorageServiceClient, len(nodes))
+	for i, node := range nodes {
+		clients[i] = s.nodes[node]
+	}
+	return clients
+}
+
+// readOwners returns the nodes to try when reading key: its replicas under the
+// current placement followed, during a rebalance, by those under the previous
+// one. The caller must hold s.mutex.
+func (s *NetworkVideoContentService) readOwners(key string) ([]string, error) {
+	owners, err := s.hashRing.getNodes(key, s.replicas)
+	if err != nil || s.prevRing == nil {
+		return owners, err
+	}
+	prevOwners, err := s.prevRing.getNodes(key, s.replicas)
+	if err != nil {
+		return owners, nil
+	}
+	for _, node := range prevOwners {
+		if !slices.Contains(owners, node) {
+			owners = append(owners, node)
+		}
+	}
+	return owners, nil
+}
+
 func (s *NetworkVideoContentService) Write(videoId string, filename string, data []byte) error {
+	w, err := s.Create(videoId, filename)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return closeContent(w, err)
+	}
+	return w.Close()
+}
+
+// CheckWritable returns ErrContentUnavailable if a storage node is down, as
+// the files of a new video would be placed on all of them.
+func (s *NetworkVideoContentService) CheckWritable() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, addr := range s.allNodes {
+		if s.isDown(addr) {
+			return fmt.Errorf("%w: storage node %v is down", ErrContentUnavailable, addr)
+		}
+	}
+	return nil
+}
+
+// Create streams the file to all of its replicas at once. Close fails if any
+// of them did not store it, so it fails at once if one of them is down.
+func (s *NetworkVideoContentService) Create(videoId string, filename string) (io.WriteCloser, error) {
 	key := fmt.Sprintf("%v/%v", videoId, filename)
+
+	// During a rebalance the key is written to both placements, so the
+	// previous one stays complete if the rebalance has to be aborted.
 	s.mutex.Lock()
-	s.allKeys = append(s.allKeys, key)
+	s.allKeys[key] = struct{}{}
+	if s.prevRing != nil {
+		s.rebalance.written[key] = struct{}{}
+	}
+	owners, err := s.readOwners(key)
+	clients := s.clientsFor(owners)
+	down := slices.IndexFunc(owners, s.isDown)
 	s.mutex.Unlock()
 
-	node, err := s.hashRing.getNode(key)
-	fmt.Printf("Node for %v: %v\n", key, node)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	client := s.nodes[node]
-	_, err = client.StoreFile(context.Background(), &proto.StoreFileRequest{Key: key, Data: data})
-	return err
+	if down >= 0 {
+		return nil, fmt.Errorf("%w: write %v to %v failed: node is down", ErrContentUnavailable, key, owners[down])
+	}
+
+	w := &replicaWriter{
+		pipes: make([]*io.PipeWriter, len(owners)),
+		errs:  make([]error, len(owners)),
+	}
+	writers := make([]io.Writer, len(owners))
+	for i, node := range owners {
+		pr, pw := io.Pipe()
+		w.pipes[i] = pw
+		writers[i] = pw
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			_, err := storeFile(context.Background(), clients[i], key, pr)
+			if err != nil {
+				err = fmt.Errorf("write %v to %v failed: %v", key, node, err)
+			}
+			// Unblock the writer if the node gave up before the end.
+			pr.CloseWithError(cmp.Or(err, io.ErrClosedPipe))
+			w.errs[i] = err
+		}()
+	}
+	w.Writer = io.MultiWriter(writers...)
+	return w, nil
+}
+
+// replicaWriter streams a file to several storage nodes, each through a pipe
+// read by its own StoreFileStream call.
+type replicaWriter struct {
+	io.Writer
+	pipes []*io.PipeWriter
+	wg    sync.WaitGroup
+	errs  []error
+}
+
+func (w *replicaWriter) Close() error {
+	for _, pw := range w.pipes {
+		pw.Close()
+	}
+	w.wg.Wait()
+	return errors.Join(w.errs...)
+}
+
+// CloseWithError aborts the upload, which makes every node discard the
+// partial file.
+func (w *replicaWriter) CloseWithError(err error) error {
+	for _, pw := range w.pipes {
+		pw.CloseWithError(err)
+	}
+	w.wg.Wait()
+	return nil
 }
 
 func (s *NetworkVideoContentService) Read(videoId string, filename string) ([]byte, error) {
 	key := fmt.Sprintf("%v/%v", videoId, filename)
-	node, err := s.hashRing.getNode(key)
+
+	s.mutex.RLock()
+	owners, err := s.readOwners(key)
+	// Nodes that are known to be down are only tried as a last resort.
+	slices.SortStableFunc(owners, func(a, b string) int {
+		return cmp.Compare(boolToInt(s.isDown(a)), boolToInt(s.isDown(b)))
+	})
+	clients := s.clientsFor(owners)
+	s.mutex.RUnlock()
 	if err != nil {
 		return nil, err
 	}
 
-	client := s.nodes[node]
-	resp, err := client.GetFile(context.Background(), &proto.GetFileRequest{Key: key})
+	// Fall back to the next replica when a node fails.
+	var errs []error
+	for i, node := range owners {
+		var buf bytes.Buffer
+		if _, err := getFile(context.Background(), clients[i], key, &buf); err != nil {
+			log.Printf("Read %v from %v failed: %v", key, node, err)
+			errs = append(errs, err)
+			continue
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, readError(key, errs)
+}
+
+// readError returns the error of reading key when every owner failed with
+// errs: ErrContentNotFound if none of them has it, ErrContentUnavailable if
+// the others cannot be reached, and the last error otherwise.
+func readError(key string, errs []error) error {
+	notFound, unavailable := true, true
+	for _, err := range errs {
+		switch status.Code(err) {
+		case codes.NotFound:
+		case codes.Unavailable:
+			notFound = false
+		default:
+			notFound, unavailable = false, false
+		}
+	}
+	lastErr := errs[len(errs)-1]
+	switch {
+	case notFound:
+		return fmt.Errorf("%w: %v", ErrContentNotFound, key)
+	case unavailable:
+		return fmt.Errorf("%w: %v", ErrContentUnavailable, lastErr)
+	}
+	return lastErr
+}
+
+// Open streams the file from the first of its replicas that can be reached.
+// Once reading has started, a failing node is not replaced by another.
+func (s *NetworkVideoContentService) Open(videoId string, filename string) (io.ReadCloser, int64, error) {
+	key := fmt.Sprintf("%v/%v", videoId, filename)
+
+	s.mutex.RLock()
+	owners, err := s.readOwners(key)
+	slices.SortStableFunc(owners, func(a, b string) int {
+		return cmp.Compare(boolToInt(s.isDown(a)), boolToInt(s.isDown(b)))
+	})
+	clients := s.clientsFor(owners)
+	s.mutex.RUnlock()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return resp.Data, err
+
+	var errs []error
+	for i, node := range owners {
+		r, err := openFile(context.Background(), clients[i], key)
+		if err != nil {
+			log.Printf("Open %v on %v failed: %v", key, node, err)
+			errs = append(errs, err)
+			continue
+		}
+		return r, r.size, nil
+	}
+	return nil, 0, readError(key, errs)
 }
 
-func (s *NetworkVideoContentService) AddNode(ctx context.Context, req *proto.AddNodeRequest) (*proto.AddNodeResponse, error) {
+// Delete removes every file of the video from all nodes, including stale
+// copies left outside the video's current replicas.
+func (s *NetworkVideoContentService) Delete(videoId string) error {
+	prefix := videoId + "/"
+	ctx := context.Background()
+
+	s.mutex.Lock()
+	clients := maps.Clone(s.nodes)
+	for key := range s.allKeys {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.allKeys, key)
+			// Keys marked as written are skipped by a running migration,
+			// which would otherwise fail to copy them.
+			if s.rebalance != nil {
+				s.rebalance.written[key] = struct{}{}
+			}
+		}
+	}
+	s.mutex.Unlock()
+
+	var errs []error
+	for addr, client := range clients {
+		keys, err := listKeys(ctx, client, prefix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("list keys of %v on %v failed: %v", videoId, addr, err))
+			continue
+		}
+		for _, key := range keys {
+			if _, err := client.DeleteFile(ctx, &proto.DeleteFileRequest{Key: key}); err != nil {
+				errs = append(errs, fmt.Errorf("delete %v from %v failed: %v", key, addr, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// listKeys returns every key stored on the node that starts with prefix.
+func listKeys(ctx context.Context, client proto.VideoContentStorageServiceClient, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		resp, err := client.ListKeys(ctx, &proto.ListKeysRequest{Prefix: prefix, PageToken: token})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, resp.Keys...)
+		if resp.NextPageToken == "" {
+			return keys, nil
+		}
+		token = resp.NextPageToken
+	}
+}
+
+// RebuildIndex adds the keys currently stored on the storage nodes to the key
+// index, so that keys written before a restart are migrated too.
+func (s *NetworkVideoContentService) RebuildIndex(ctx context.Context) error {
+	_, err := s.rebuildIndex(ctx)
+	return err
+}
+
+// rebuildIndex lists the keys of every node without holding s.mutex, merges
+// them into the key index and returns them.
+func (s *NetworkVideoContentService) rebuildIndex(ctx context.Context) ([]string, error) {
+	s.mutex.RLock()
+	clients := maps.Clone(s.nodes)
+	s.mutex.RUnlock()
+
+	found := make(map[string]struct{})
+	for addr, client := range clients {
+		nodeKeys, err := listKeys(ctx, client, "")
+		if err != nil {
+			return nil, fmt.Errorf("list keys on %v failed: %v", addr, err)
+		}
+		for _, key := range nodeKeys {
+			found[key] = struct{}{}
+		}
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		s.allKeys[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
 
+// InitNodes adds the nodes of an existing cluster to the ring without
+// migrating any keys, since their files are already placed for the ring of
+// all of them. Adding them one by one with AddNode would instead plan each
+// migration as if the keys were placed for the partial ring. Each node is
+// given as addr or addr=weight, with weight 1 by default, as logged after
+// each rebalance. It must be called before the service is used.
+func (s *NetworkVideoContentService) InitNodes(nodes []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, node := range nodes {
+		addr, weight, err := parseNode(node)
+		if err != nil {
+			return err
+		}
+		if _, exists := s.nodes[addr]; exists {
+			return fmt.Errorf("node %v already exists", addr)
+		}
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return err
+		}
+		s.nodes[addr] = proto.NewVideoContentStorageServiceClient(conn)
+		s.conns[addr] = conn
+		s.allNodes = append(s.allNodes, addr)
+		s.health[addr] = &nodeHealth{state: healthUp}
+		s.hashRing.addNode(addr, weight)
+	}
+	return nil
+}
+
+// parseNode parses a node given to InitNodes.
+func parseNode(node string) (string, int, error) {
+	addr, w, hasWeight := strings.Cut(node, "=")
+	if !hasWeight {
+		return addr, 1, nil
+	}
+	weight, err := strconv.Atoi(w)
+	if err != nil || weight < 1 {
+		return "", 0, fmt.Errorf("invalid weight of node %v: %q", addr, w)
+	}
+	return addr, weight, nil
+}
+
+// nodeList returns the nodes in the ring in the form InitNodes takes, so that
+// a server restarted with them places keys the same way. The caller must hold
+// s.mutex.
+func (s *NetworkVideoContentService) nodeList() []string {
+	var nodes []string
+	for _, addr := range s.allNodes {
+		switch weight := s.hashRing.weight(addr); weight {
+		case 0:
+			// Joining, and not in the ring yet.
+		case 1:
+			nodes = append(nodes, addr)
+		default:
+			nodes = append(nodes, fmt.Sprintf("%v=%d", addr, weight))
+		}
+	}
+	return nodes
+}
+
+// AddNode adds a node to the ring and migrates the keys it now owns in the
+// background. Unless req.Background is set, it waits for the migration to
+// finish or ctx to end. If some keys cannot be copied to the node, the node is
+// not added and the error lists the keys.
+func (s *NetworkVideoContentService) AddNode(ctx context.Context, req *proto.AddNodeRequest) (*proto.AddNodeResponse, error) {
 	addr := req.NodeAddress
+
+	s.mutex.Lock()
+	if s.rebalance != nil {
+		s.mutex.Unlock()
+		return &proto.AddNodeResponse{MigratedFileCount: 0}, fmt.Errorf("rebalance of node %v in progress", s.rebalance.node)
+	}
 	if _, exists := s.nodes[addr]; exists {
-		return &proto.AddNodeResponse{MigratedFileCount: 0}, fmt.Errorf("node %v does not exist", addr)
+		s.mutex.Unlock()
+		return &proto.AddNodeResponse{MigratedFileCount: 0}, fmt.Errorf("node %v already exists", addr)
 	}
 
 	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
+		s.mutex.Unlock()
 		return &proto.AddNodeResponse{MigratedFileCount: 0}, err
 	}
 
-	client := proto.NewVideoContentStorageServiceClient(conn)
-	s.nodes[addr] = client
+	rb := newRebalance(addr, nodeJoining)
+	s.rebalance = rb
+	s.nodes[addr] = proto.NewVideoContentStorageServiceClient(conn)
 	s.conns[addr] = conn
 	s.allNodes = append(s.allNodes, addr)
+	s.health[addr] = &nodeHealth{state: healthUp}
+	s.mutex.Unlock()
 
-	migrated := int32(0)
-	for _, key := range s.allKeys {
-		oldNode, err := s.hashRing.getNode(key)
-		if err != nil {
-			continue
-		}
-		s.hashRing.addNode(addr)
-		newNode, err := s.hashRing.getNode(key)
-		if err != nil {
-			continue
-		}
-		s.hashRing.removeNode(addr)
+	keys, err := s.rebuildIndex(ctx)
+	if err != nil {
+		s.mutex.Lock()
+		s.dropNode(addr)
+		s.rebalance = nil
+		s.mutex.Unlock()
+		close(rb.done)
+		return &proto.AddNodeResponse{MigratedFileCount: 0}, err
+	}
 
-		if oldNode != newNode {
-			data, err := s.nodes[oldNode].GetFile(ctx, &proto.GetFileRequest{Key: key})
-			if err != nil {
-				continue
-			}
+	s.mutex.Lock()
+	after := s.hashRing.clone()
+	after.addNode(addr, int(req.Weight))
+	s.startRebalance(rb, after, keys)
+	s.mutex.Unlock()
 
-			_, err = client.StoreFile(ctx, &proto.StoreFileRequest{Key: key, Data: data.Data})
-			if err != nil {
-				continue
-			}
-			_, err = s.nodes[oldNode].DeleteFile(ctx, &proto.DeleteFileRequest{Key: key})
-			if err != nil {
-				continue
-			}
-			migrated++
-		}
+	if req.Background {
+		return &proto.AddNodeResponse{MigratedFileCount: 0}, nil
+	}
+	select {
+	case <-rb.done:
+		return &proto.AddNodeResponse{MigratedFileCount: rb.migrated()}, rb.err()
+	case <-ctx.Done():
+		return &proto.AddNodeResponse{MigratedFileCount: 0}, fmt.Errorf("migration to %v continues in background: %v", addr, ctx.Err())
 	}
-
-	s.hashRing.addNode(addr)
-	return &proto.AddNodeResponse{MigratedFileCount: migrated}, nil
 }
 
+// RemoveNode takes a node off the ring and migrates its keys to their new
+// owners in the background. The node keeps serving reads until the migration
+// finishes. Unless req.Background is set, it waits for the migration to finish
+// or ctx to end. The node is only removed once all of its keys are copied;
+// otherwise it stays in the ring and the error lists the keys.
 func (s *NetworkVideoContentService) RemoveNode(ctx context.Context, req *proto.RemoveNodeRequest) (*proto.RemoveNodeResponse, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
 	addr := req.NodeAddress
-	client, exists := s.nodes[addr]
-	if !exists {
+
+	s.mutex.Lock()
+	if s.rebalance != nil {
+		s.mutex.Unlock()
+		return &proto.RemoveNodeResponse{MigratedFileCount: 0}, fmt.Errorf("rebalance of node %v in progress", s.rebalance.node)
+	}
+	if _, exists := s.nodes[addr]; !exists {
+		s.mutex.Unlock()
 		return &proto.RemoveNodeResponse{MigratedFileCount: 0}, fmt.Errorf("node %s does not exist", addr)
 	}
+	rb := newRebalance(addr, nodeLeaving)
+	s.rebalance = rb
+	s.mutex.Unlock()
 
-	migrated := int32(0)
-	for _, key := range s.allKeys {
-		oldNode, err := s.hashRing.getNode(key)
-		if err != nil {
-			continue
-		}
+	keys, err := s.rebuildIndex(ctx)
+	if err != nil {
+		s.mutex.Lock()
+		s.rebalance = nil
+		s.mutex.Unlock()
+		close(rb.done)
+		return &proto.RemoveNodeResponse{MigratedFileCount: 0}, err
+	}
 
-		if oldNode == addr {
-			s.hashRing.removeNode(addr)
-			newNode, err := s.hashRing.getNode(key)
-			if err != nil {
-				continue
-			}
+	s.mutex.Lock()
+	if len(s.nodes) == 1 && len(keys) > 0 {
+		s.rebalance = nil
+		s.mutex.Unlock()
+		close(rb.done)
+		return &proto.RemoveNodeResponse{MigratedFileCount: 0}, status.Errorf(codes.FailedPrecondition, "cannot remove the last node %v while it holds %d keys", addr, len(keys))
+	}
+	after := s.hashRing.clone()
+	after.removeNode(addr)
+	s.startRebalance(rb, after, keys)
+	s.mutex.Unlock()
 
-			data, err := client.GetFile(ctx, &proto.GetFileRequest{Key: key})
-			if err != nil {
-				continue
-			}
-			_, err = s.nodes[newNode].StoreFile(ctx, &proto.StoreFileRequest{Key: key, Data: data.Data})
-			if err != nil {
-				continue
-			}
-			_, err = client.DeleteFile(ctx, &proto.DeleteFileRequest{Key: key})
-			if err != nil {
-				continue
-			}
-			migrated++
-			s.hashRing.addNode(addr)
-		}
+	if req.Background {
+		return &proto.RemoveNodeResponse{MigratedFileCount: 0}, nil
+	}
+	select {
+	case <-rb.done:
+		return &proto.RemoveNodeResponse{MigratedFileCount: rb.migrated()}, rb.err()
+	case <-ctx.Done():
+		return &proto.RemoveNodeResponse{MigratedFileCount: 0}, fmt.Errorf("migration from %v continues in background: %v", addr, ctx.Err())
 	}
+}
 
+// dropNode closes the connection to addr and forgets it. The caller must hold
+// s.mutex.
+func (s *NetworkVideoContentService) dropNode(addr string) {
 	s.conns[addr].Close()
 	delete(s.conns, addr)
 	delete(s.nodes, addr)
-	s.hashRing.removeNode(addr)
+	delete(s.health, addr)
 
 	for i, nodeAddr := range s.allNodes {
 		if nodeAddr == addr {
@@ -248,13 +862,45 @@ func (s *NetworkVideoContentService) RemoveNode(ctx context.Context, req *proto.
 			break
 		}
 	}
-
-	return &proto.RemoveNodeResponse{MigratedFileCount: migrated}, nil
 }
 
 func (s *NetworkVideoContentService) ListNodes(ctx context.Context, req *proto.ListNodesRequest) (*proto.ListNodesResponse, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	return &proto.ListNodesResponse{Nodes: s.allNodes}, nil
+	infos := make([]*proto.NodeInfo, 0, len(s.allNodes))
+	for _, addr := range s.allNodes {
+		state := nodeActive
+		if s.rebalance != nil && s.rebalance.node == addr {
+			state = s.rebalance.state
+		}
+		info := &proto.NodeInfo{Address: addr, State: state, Weight: int32(s.hashRing.weight(addr))}
+		if h, ok := s.health[addr]; ok {
+			info.Health = h.state
+			if !h.lastSeen.IsZero() {
+				info.LastSeen = timestamppb.New(h.lastSeen)
+			}
+		}
+		infos = append(infos, info)
+	}
+	return &proto.ListNodesResponse{Nodes: slices.Clone(s.allNodes), NodeInfos: infos}, nil
+}
+
+func (s *NetworkVideoContentService) VerifyNode(ctx context.Context, req *proto.VerifyNodeRequest) (*proto.VerifyNodeResponse, error) {
+	s.mutex.RLock()
+	client, exists := s.nodes[req.NodeAddress]
+	s.mutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("node %s does not exist", req.NodeAddress)
+	}
+
+	resp, err := client.VerifyFiles(ctx, &proto.VerifyFilesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("verify %v failed: %v", req.NodeAddress, err)
+	}
+	return &proto.VerifyNodeResponse{
+		CheckedCount:   resp.CheckedCount,
+		CorruptKeys:    resp.CorruptKeys,
+		UnverifiedKeys: resp.UnverifiedKeys,
+	}, nil
 }
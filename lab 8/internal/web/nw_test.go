@@ -0,0 +1,176 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ringKeys returns n keys to place on test rings.
+func ringKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("video%d/manifest.mpd", i)
+	}
+	return keys
+}
+
+// placement returns the owners of each key on h.
+func placement(t *testing.T, h *HashRing, keys []string, replicas int) map[string][]string {
+	t.Helper()
+	owners := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		nodes, err := h.getNodes(key, replicas)
+		if err != nil {
+			t.Fatalf("getNodes %v: %v", key, err)
+		}
+		owners[key] = nodes
+	}
+	return owners
+}
+
+func TestHashRingWeights(t *testing.T) {
+	h := NewHashRing(64)
+	h.addNode("a", 1)
+	h.addNode("b", 3)
+	h.addNode("c", 0)
+	for addr, want := range map[string]int{"a": 1, "b": 3, "c": 1, "d": 0} {
+		if got := h.weight(addr); got != want {
+			t.Errorf("weight of %v = %d, want %d", addr, got, want)
+		}
+	}
+
+	keys := ringKeys(10000)
+	primaries := make(map[string]int)
+	for _, owners := range placement(t, h, keys, 2) {
+		if len(owners) != 2 || owners[0] == owners[1] {
+			t.Fatalf("owners = %v, want 2 distinct nodes", owners)
+		}
+		primaries[owners[0]]++
+	}
+	// b holds three fifths of the virtual nodes.
+	if share := float64(primaries["b"]) / float64(len(keys)); share < 0.5 || share > 0.7 {
+		t.Errorf("b is the primary of %.2f of the keys, want about 0.6 (%v)", share, primaries)
+	}
+
+	h.removeNode("b")
+	if got := h.weight("b"); got != 0 {
+		t.Errorf("weight of removed node = %d, want 0", got)
+	}
+	for key, owners := range placement(t, h, keys, 3) {
+		if slices.Contains(owners, "b") || len(owners) != 2 {
+			t.Fatalf("owners of %v after removing b = %v", key, owners)
+		}
+	}
+}
+
+func TestHashRingClone(t *testing.T) {
+	h := NewHashRing(16)
+	h.addNode("a", 1)
+	h.addNode("b", 3)
+	keys := ringKeys(1000)
+	before := placement(t, h, keys, 2)
+
+	c := h.clone()
+	if got := placement(t, c, keys, 2); !mapsEqual(got, before) {
+		t.Fatalf("clone places keys differently from the original")
+	}
+	if got := c.weight("b"); got != 3 {
+		t.Errorf("weight of b in clone = %d, want 3", got)
+	}
+
+	// Changes to the clone leave the original alone, and the other way round.
+	c.addNode("c", 2)
+	c.removeNode("a")
+	if got := placement(t, h, keys, 2); !mapsEqual(got, before) {
+		t.Errorf("changing the clone moved keys on the original")
+	}
+	h.removeNode("b")
+	if got := c.weight("b"); got != 3 {
+		t.Errorf("weight of b in clone after removing it from the original = %d, want 3", got)
+	}
+
+	// The clone matches a ring built from scratch with the same nodes.
+	fresh := NewHashRing(16)
+	fresh.addNode("b", 3)
+	fresh.addNode("c", 2)
+	if !mapsEqual(placement(t, c, keys, 2), placement(t, fresh, keys, 2)) {
+		t.Errorf("changed clone places keys differently from a fresh ring")
+	}
+}
+
+func mapsEqual(a, b map[string][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, owners := range a {
+		if !slices.Equal(owners, b[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseNode(t *testing.T) {
+	tests := []struct {
+		node   string
+		addr   string
+		weight int
+		ok     bool
+	}{
+		{"localhost:8090", "localhost:8090", 1, true},
+		{"localhost:8090=3", "localhost:8090", 3, true},
+		{"localhost:8090=0", "", 0, false},
+		{"localhost:8090=x", "", 0, false},
+	}
+	for _, tt := range tests {
+		addr, weight, err := parseNode(tt.node)
+		if (err == nil) != tt.ok || addr != tt.addr || weight != tt.weight {
+			t.Errorf("parseNode(%q) = %q, %d, %v", tt.node, addr, weight, err)
+		}
+	}
+}
+
+func TestCreateFailsOnDownNode(t *testing.T) {
+	addrs, dirs := startStorageNodes(t, 3)
+	svc := NewNetworkVideoContentService(2, 16)
+	if err := svc.InitNodes(addrs); err != nil {
+		t.Fatalf("InitNodes: %v", err)
+	}
+	if err := svc.CheckWritable(); err != nil {
+		t.Fatalf("CheckWritable with every node up: %v", err)
+	}
+
+	svc.mutex.Lock()
+	svc.health[addrs[0]].state = healthDown
+	svc.mutex.Unlock()
+	if err := svc.CheckWritable(); !errors.Is(err, ErrContentUnavailable) {
+		t.Errorf("CheckWritable = %v, want ErrContentUnavailable", err)
+	}
+
+	// Every key owned by the down node fails without being written anywhere.
+	for _, key := range ringKeys(20) {
+		owners, err := svc.hashRing.getNodes(key, svc.replicas)
+		if err != nil {
+			t.Fatalf("getNodes %v: %v", key, err)
+		}
+		videoId, filename, _ := strings.Cut(key, "/")
+		err = svc.Write(videoId, filename, []byte("data"))
+		if !slices.Contains(owners, addrs[0]) {
+			if err != nil {
+				t.Errorf("write %v to up nodes: %v", key, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrContentUnavailable) {
+			t.Errorf("write %v = %v, want ErrContentUnavailable", key, err)
+		}
+		for i := range addrs {
+			if onDisk(dirs[i], key) {
+				t.Errorf("%v was written to node %d", key, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Fields videos can be sorted by.
+const (
+	SortByUploadedAt = "uploaded_at"
+	SortByTitle      = "title"
+	SortByDuration   = "duration"
+)
+
+// ErrInvalidQuery is returned by List for a malformed VideoQuery.
+var ErrInvalidQuery = errors.New("invalid video query")
+
+// VideoQuery selects a page of videos.
+type VideoQuery struct {
+	// Text, if set, keeps the videos whose ID, title or description contain
+	// it, ignoring case.
+	Text string
+	// SortBy is one of the SortBy constants, SortByUploadedAt by default.
+	// Videos with the same sort value are ordered by ID.
+	SortBy string
+	// Ascending sorts in ascending order instead of the default descending.
+	Ascending bool
+	// Limit is the maximum number of videos returned, or 0 for all.
+	Limit int
+	// Cursor is the NextCursor or PrevCursor of a page returned for the same
+	// query, or empty for the first page.
+	Cursor string
+}
+
+// VideoPage is the result of a VideoQuery.
+type VideoPage struct {
+	Videos []VideoMetadata
+	// NextCursor and PrevCursor select the following and preceding pages,
+	// and are empty if there are none.
+	NextCursor string
+	PrevCursor string
+}
+
+// validate fills in the defaults of q and checks its fields.
+func (q *VideoQuery) validate() error {
+	switch q.SortBy {
+	case "":
+		q.SortBy = SortByUploadedAt
+	case SortByUploadedAt, SortByTitle, SortByDuration:
+	default:
+		return fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, q.SortBy)
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// videoCursor is the position of a page boundary in the order of a query.
+type videoCursor struct {
+	SortBy    string `json:"s"`
+	Ascending bool   `json:"a"`
+	// Value and Id are the sort value and ID of the video at the boundary.
+	Value any    `json:"v"`
+	Id    string `json:"i"`
+	// Backward selects the videos before the boundary instead of after it.
+	Backward bool `json:"b,omitempty"`
+}
+
+func encodeCursor(q VideoQuery, value any, id string, backward bool) string {
+	c := videoCursor{
+		SortBy:    q.SortBy,
+		Ascending: q.Ascending,
+		Value:     value,
+		Id:        id,
+		Backward:  backward,
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses the cursor of q, which must have been returned for a
+// query with the same order. It returns nil if q has no cursor.
+func decodeCursor(q VideoQuery) (*videoCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c videoCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.SortBy != q.SortBy || c.Ascending != q.Ascending {
+		return nil, fmt.Errorf("%w: cursor belongs to a different sort order", ErrInvalidQuery)
+	}
+	return &c, nil
+}
+
+// pageOf builds the page for q from videos fetched in query order after the
+// cursor c, or in reverse order before it if c is backward. keys holds the
+// sort value of each video. Up to Limit+1 videos are expected, the extra one
+// indicating that more follow.
+func pageOf(q VideoQuery, c *videoCursor, videos []VideoMetadata, keys []any) *VideoPage {
+	more := q.Limit > 0 && len(videos) > q.Limit
+	if more {
+		videos, keys = videos[:q.Limit], keys[:q.Limit]
+	}
+	backward := c != nil && c.Backward
+	if backward {
+		slices.Reverse(videos)
+		slices.Reverse(keys)
+	}
+
+	page := &VideoPage{Videos: videos}
+	if len(videos) == 0 {
+		return page
+	}
+	// Paging forward there is a previous page iff a cursor was given, and
+	// paging backward there is a next page.
+	hasNext, hasPrev := more, c != nil
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if last := len(videos) - 1; hasNext {
+		page.NextCursor = encodeCursor(q, keys[last], videos[last].Id, false)
+	}
+	if hasPrev {
+		page.PrevCursor = encodeCursor(q, keys[0], videos[0].Id, true)
+	}
+	return page
+}
@@ -0,0 +1,437 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"tritontube/internal/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Membership states reported by ListNodes.
+const (
+	nodeActive  = "active"
+	nodeJoining = "joining"
+	nodeLeaving = "leaving"
+)
+
+// progressInterval is how often WatchMigration reports progress.
+const progressInterval = 500 * time.Millisecond
+
+// Keys that fail to migrate are retried up to migrationAttempts times in
+// total, waiting migrationBackoff before the first retry and doubling the wait
+// up to maxMigrationBackoff.
+const (
+	migrationAttempts   = 5
+	migrationBackoff    = 500 * time.Millisecond
+	maxMigrationBackoff = 8 * time.Second
+)
+
+// rebalance tracks the migration that follows a node joining or leaving the
+// ring. While it runs, writes go to both placements and reads try the new
+// placement before falling back to the previous one.
+type rebalance struct {
+	node  string
+	state string
+	// written holds keys written to the new placement since the migration
+	// started, which must not be overwritten with older copies. It is
+	// guarded by NetworkVideoContentService.mutex.
+	written map[string]struct{}
+	done    chan struct{}
+
+	mutex       sync.Mutex
+	started     time.Time
+	keysPlanned int32
+	keysMoved   int32
+	keysFailed  int32
+	bytesMoved  int64
+	// failed maps the keys that could not be migrated to their last error.
+	// It is final once done is closed.
+	failed map[string]error
+	// copied maps each key to the nodes this rebalance copied it to, which
+	// are the only copies an aborted rebalance may delete.
+	copied map[string][]string
+}
+
+func newRebalance(node string, state string) *rebalance {
+	return &rebalance{
+		node:    node,
+		state:   state,
+		written: make(map[string]struct{}),
+		done:    make(chan struct{}),
+		started: time.Now(),
+		copied:  make(map[string][]string),
+	}
+}
+
+// migrated returns the number of keys moved so far.
+func (rb *rebalance) migrated() int32 {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	return rb.keysMoved
+}
+
+// err returns a FailedPrecondition error listing the keys that could not be
+// migrated, or nil if the rebalance succeeded. It must only be called once
+// done is closed.
+func (rb *rebalance) err() error {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	if len(rb.failed) == 0 {
+		return nil
+	}
+
+	action := "added"
+	if rb.state == nodeLeaving {
+		action = "removed"
+	}
+	st := status.Newf(codes.FailedPrecondition, "node %v was not %v: %d keys could not be migrated", rb.node, action, len(rb.failed))
+
+	violations := make([]*errdetails.PreconditionFailure_Violation, 0, len(rb.failed))
+	for _, key := range slices.Sorted(maps.Keys(rb.failed)) {
+		violations = append(violations, &errdetails.PreconditionFailure_Violation{
+			Type:        "UNMIGRATED_KEY",
+			Subject:     key,
+			Description: rb.failed[key].Error(),
+		})
+	}
+	if detailed, err := st.WithDetails(&errdetails.PreconditionFailure{Violations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// progress returns a snapshot of the migration's progress.
+func (rb *rebalance) progress() *proto.MigrationProgress {
+	select {
+	case <-rb.done:
+		p := rb.snapshot()
+		p.Done = true
+		if err := rb.err(); err != nil {
+			p.Error = status.Convert(err).Message()
+		}
+		p.FailedKeys = slices.Sorted(maps.Keys(rb.failed))
+		return p
+	default:
+		return rb.snapshot()
+	}
+}
+
+func (rb *rebalance) snapshot() *proto.MigrationProgress {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	p := &proto.MigrationProgress{
+		NodeAddress: rb.node,
+		State:       rb.state,
+		KeysPlanned: rb.keysPlanned,
+		KeysMoved:   rb.keysMoved,
+		BytesMoved:  rb.bytesMoved,
+		KeysFailed:  rb.keysFailed,
+	}
+	if processed := rb.keysMoved + rb.keysFailed; processed > 0 {
+		perKey := time.Since(rb.started).Seconds() / float64(processed)
+		p.EtaSeconds = perKey * float64(rb.keysPlanned-processed)
+	}
+	return p
+}
+
+// startRebalance switches to the after placement and migrates keys to it in
+// the background. If some keys cannot be migrated, the previous placement is
+// restored. The caller must hold s.mutex.
+func (s *NetworkVideoContentService) startRebalance(rb *rebalance, after *HashRing, keys []string) {
+	// Keys written after their node was listed are only in the index. From
+	// here on, writes are marked in rb.written instead.
+	keySet := make(map[string]struct{}, len(keys)+len(s.allKeys))
+	for _, key := range keys {
+		keySet[key] = struct{}{}
+	}
+	maps.Copy(keySet, s.allKeys)
+	keys = slices.Sorted(maps.Keys(keySet))
+
+	before := s.hashRing
+	s.prevRing = before
+	s.hashRing = after
+	clients := maps.Clone(s.nodes)
+
+	go func() {
+		failed := s.migrate(context.Background(), rb, clients, keys, before, after)
+		rb.mutex.Lock()
+		rb.failed = failed
+		rb.mutex.Unlock()
+
+		s.mutex.Lock()
+		if len(failed) > 0 {
+			log.Printf("Rebalance of %v aborted: %d keys could not be migrated", rb.node, len(failed))
+			s.hashRing = before
+			if rb.state == nodeJoining {
+				s.dropNode(rb.node)
+			}
+		} else {
+			log.Printf("Rebalance of %v finished: %d files migrated", rb.node, rb.migrated())
+			if rb.state == nodeLeaving {
+				s.dropNode(rb.node)
+			}
+		}
+		s.prevRing = nil
+		s.rebalance = nil
+		s.lastRebalance = rb
+		log.Printf("Storage nodes are now %v", strings.Join(s.nodeList(), ","))
+		s.mutex.Unlock()
+		close(rb.done)
+	}()
+}
+
+// writtenDuringRebalance reports whether key was written to the new placement
+// after rb started.
+func (s *NetworkVideoContentService) writtenDuringRebalance(rb *rebalance, key string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := rb.written[key]
+	return ok
+}
+
+// move is a key whose replica set changes during a rebalance.
+type move struct {
+	key                  string
+	oldOwners, newOwners []string
+}
+
+// planMoves returns the keys whose replica set differs between the before and
+// after rings.
+func (s *NetworkVideoContentService) planMoves(keys []string, before, after *HashRing) []move {
+	var plan []move
+	for _, key := range keys {
+		oldOwners, err := before.getNodes(key, s.replicas)
+		if err != nil {
+			continue
+		}
+		newOwners, err := after.getNodes(key, s.replicas)
+		if err != nil {
+			continue
+		}
+		if !slices.Equal(oldOwners, newOwners) {
+			plan = append(plan, move{key, oldOwners, newOwners})
+		}
+	}
+	return plan
+}
+
+// migrate copies every key whose replica set differs between the before and
+// after rings to its new owners, retrying failed keys with backoff, and
+// records progress in rb. Only once every key is copied does it delete the
+// keys from the owners that no longer hold them, so the previous placement
+// stays complete if it returns failures. It returns the keys that could not be
+// copied. clients must contain every node of both rings.
+func (s *NetworkVideoContentService) migrate(ctx context.Context, rb *rebalance, clients map[string]proto.VideoContentStorageServiceClient, keys []string, before, after *HashRing) map[string]error {
+	plan := s.planMoves(keys, before, after)
+
+	rb.mutex.Lock()
+	rb.keysPlanned = int32(len(plan))
+	rb.mutex.Unlock()
+
+	failed := make(map[string]error)
+	pending := plan
+	backoff := migrationBackoff
+	held := newHeldKeys()
+	for attempt := 1; ; attempt++ {
+		held.list(ctx, clients, pending)
+		var retry []move
+		for _, m := range pending {
+			n, err := s.copyToNewOwners(ctx, rb, clients, held, m)
+
+			rb.mutex.Lock()
+			rb.bytesMoved += n
+			if err == nil {
+				delete(failed, m.key)
+				rb.keysMoved++
+			} else {
+				failed[m.key] = err
+				retry = append(retry, m)
+			}
+			rb.keysFailed = int32(len(failed))
+			rb.mutex.Unlock()
+		}
+
+		if len(retry) == 0 || attempt == migrationAttempts {
+			break
+		}
+		log.Printf("Rebalance of %v: retrying %d keys in %v", rb.node, len(retry), backoff)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxMigrationBackoff)
+		pending = retry
+	}
+
+	if len(failed) > 0 {
+		// The copies this rebalance made are extra replicas under the
+		// previous placement, which is kept. Copies that were on the new
+		// owners already may be the only ones, so they stay.
+		rb.mutex.Lock()
+		copied := maps.Clone(rb.copied)
+		rb.mutex.Unlock()
+		for key, nodes := range copied {
+			s.deleteFromOwners(ctx, clients, key, nodes, nil)
+		}
+		// Keys written during the rebalance also went to the previous
+		// placement.
+		s.mutex.RLock()
+		written := slices.Collect(maps.Keys(rb.written))
+		s.mutex.RUnlock()
+		for _, m := range s.planMoves(written, before, after) {
+			s.deleteFromOwners(ctx, clients, m.key, m.newOwners, m.oldOwners)
+		}
+		return failed
+	}
+
+	for _, m := range plan {
+		s.deleteFromOwners(ctx, clients, m.key, m.oldOwners, m.newOwners)
+	}
+	// Keys written during the rebalance also went to the previous placement.
+	s.mutex.RLock()
+	written := slices.Collect(maps.Keys(rb.written))
+	s.mutex.RUnlock()
+	for _, m := range s.planMoves(written, before, after) {
+		s.deleteFromOwners(ctx, clients, m.key, m.oldOwners, m.newOwners)
+	}
+	return nil
+}
+
+// heldKeys is the set of keys each node receiving keys in a rebalance held,
+// listed once per node rather than looked up per key.
+type heldKeys struct {
+	keys map[string]map[string]struct{}
+	// errs holds the nodes that could not be listed, which are listed again
+	// on the next attempt.
+	errs map[string]error
+}
+
+func newHeldKeys() *heldKeys {
+	return &heldKeys{
+		keys: make(map[string]map[string]struct{}),
+		errs: make(map[string]error),
+	}
+}
+
+// list lists the keys of the new owners in moves that are not listed yet.
+func (h *heldKeys) list(ctx context.Context, clients map[string]proto.VideoContentStorageServiceClient, moves []move) {
+	nodes := make(map[string]struct{})
+	for _, m := range moves {
+		for _, dst := range m.newOwners {
+			if _, ok := h.keys[dst]; !ok && !slices.Contains(m.oldOwners, dst) {
+				nodes[dst] = struct{}{}
+			}
+		}
+	}
+	for dst := range nodes {
+		keys, err := listKeys(ctx, clients[dst], "")
+		if err != nil {
+			h.errs[dst] = err
+			continue
+		}
+		delete(h.errs, dst)
+		h.keys[dst] = make(map[string]struct{}, len(keys))
+		for _, key := range keys {
+			h.keys[dst][key] = struct{}{}
+		}
+	}
+}
+
+// copyToNewOwners copies m.key from one of its old owners to each new owner
+// that does not hold it yet, and returns the number of bytes copied.
+func (s *NetworkVideoContentService) copyToNewOwners(ctx context.Context, rb *rebalance, clients map[string]proto.VideoContentStorageServiceClient, held *heldKeys, m move) (int64, error) {
+	if s.writtenDuringRebalance(rb, m.key) {
+		return 0, nil
+	}
+
+	var copied int64
+	var errs []error
+	for _, dst := range m.newOwners {
+		if slices.Contains(m.oldOwners, dst) {
+			continue
+		}
+		if err, ok := held.errs[dst]; ok {
+			errs = append(errs, fmt.Errorf("list keys of %v: %v", dst, err))
+			continue
+		}
+		if _, ok := held.keys[dst][m.key]; ok {
+			continue
+		}
+
+		var dstErrs []error
+		for _, src := range m.oldOwners {
+			n, err := copyFile(ctx, clients[src], clients[dst], m.key)
+			if err == nil {
+				copied += n
+				dstErrs = nil
+				held.keys[dst][m.key] = struct{}{}
+				rb.mutex.Lock()
+				rb.copied[m.key] = append(rb.copied[m.key], dst)
+				rb.mutex.Unlock()
+				break
+			}
+			dstErrs = append(dstErrs, fmt.Errorf("copy from %v to %v: %v", src, dst, err))
+		}
+		errs = append(errs, dstErrs...)
+	}
+	return copied, errors.Join(errs...)
+}
+
+// deleteFromOwners deletes key from every node in owners that is not in keep.
+// Failures only leave a stale extra copy behind, so they are logged.
+func (s *NetworkVideoContentService) deleteFromOwners(ctx context.Context, clients map[string]proto.VideoContentStorageServiceClient, key string, owners, keep []string) {
+	for _, node := range owners {
+		if slices.Contains(keep, node) {
+			continue
+		}
+		if _, err := clients[node].DeleteFile(ctx, &proto.DeleteFileRequest{Key: key}); err != nil {
+			log.Printf("Delete stale copy of %v from %v failed: %v", key, node, err)
+		}
+	}
+}
+
+// WatchMigration streams the progress of the running migration, or of the
+// last finished one, until it is done.
+func (s *NetworkVideoContentService) WatchMigration(req *proto.WatchMigrationRequest, stream proto.VideoContentAdminService_WatchMigrationServer) error {
+	s.mutex.RLock()
+	var rb *rebalance
+	for _, candidate := range []*rebalance{s.rebalance, s.lastRebalance} {
+		if candidate != nil && (req.NodeAddress == "" || candidate.node == req.NodeAddress) {
+			rb = candidate
+			break
+		}
+	}
+	s.mutex.RUnlock()
+	if rb == nil {
+		return status.Errorf(codes.NotFound, "no migration found for %q", req.NodeAddress)
+	}
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		p := rb.progress()
+		if err := stream.Send(p); err != nil {
+			return err
+		}
+		if p.Done {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-rb.done:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"tritontube/internal/proto"
+	"tritontube/internal/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// startStorageNodes runs n in-process storage nodes and returns their
+// addresses and base directories.
+func startStorageNodes(t *testing.T, n int) ([]string, []string) {
+	t.Helper()
+	var addrs, dirs []string
+	for i := 0; i < n; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		dir := t.TempDir()
+		srv := grpc.NewServer()
+		proto.RegisterVideoContentStorageServiceServer(srv, storage.NewStorageServer(dir))
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+		addrs = append(addrs, lis.Addr().String())
+		dirs = append(dirs, dir)
+	}
+	return addrs, dirs
+}
+
+func storageClient(t *testing.T, addr string) proto.VideoContentStorageServiceClient {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("connect to %v: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewVideoContentStorageServiceClient(conn)
+}
+
+// writeVideos writes n files through svc and returns their contents by key.
+func writeVideos(t *testing.T, svc *NetworkVideoContentService, n int) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	for i := 0; i < n; i++ {
+		videoId := fmt.Sprintf("video%d", i)
+		data := []byte(fmt.Sprintf("content of %v", videoId))
+		if err := svc.Write(videoId, "manifest.mpd", data); err != nil {
+			t.Fatalf("write %v: %v", videoId, err)
+		}
+		files[videoId+"/manifest.mpd"] = data
+	}
+	return files
+}
+
+func onDisk(dir string, key string) bool {
+	_, err := os.Stat(filepath.Join(dir, key))
+	return err == nil
+}
+
+// checkPlacement checks that every file is readable through svc and stored on
+// exactly the nodes that own it.
+func checkPlacement(t *testing.T, svc *NetworkVideoContentService, addrs, dirs []string, files map[string][]byte) {
+	t.Helper()
+	for key, want := range files {
+		videoId, filename := filepath.Split(key)
+		got, err := svc.Read(filepath.Clean(videoId), filename)
+		if err != nil {
+			t.Errorf("read %v: %v", key, err)
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("read %v = %q, want %q", key, got, want)
+		}
+
+		owners, err := svc.hashRing.getNodes(key, svc.replicas)
+		if err != nil {
+			t.Fatalf("owners of %v: %v", key, err)
+		}
+		for i, addr := range addrs {
+			if owned, stored := slices.Contains(owners, addr), onDisk(dirs[i], key); owned != stored {
+				t.Errorf("%v on node %d: stored %v, owned %v", key, i, stored, owned)
+			}
+		}
+	}
+}
+
+func TestRestartWithPopulatedNodes(t *testing.T) {
+	addrs, dirs := startStorageNodes(t, 3)
+
+	svc := NewNetworkVideoContentService(2, 16)
+	if err := svc.InitNodes(addrs); err != nil {
+		t.Fatalf("InitNodes: %v", err)
+	}
+	files := writeVideos(t, svc, 20)
+
+	// A new process joins the same nodes and finds every file in place.
+	restarted := NewNetworkVideoContentService(2, 16)
+	if err := restarted.InitNodes(addrs); err != nil {
+		t.Fatalf("InitNodes after restart: %v", err)
+	}
+	if err := restarted.RebuildIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildIndex: %v", err)
+	}
+	if len(restarted.allKeys) != len(files) {
+		t.Errorf("rebuilt index has %d keys, want %d", len(restarted.allKeys), len(files))
+	}
+	checkPlacement(t, restarted, addrs, dirs, files)
+}
+
+func TestRestartKeepsNodeWeights(t *testing.T) {
+	addrs, dirs := startStorageNodes(t, 3)
+	ctx := context.Background()
+
+	svc := NewNetworkVideoContentService(2, 16)
+	if err := svc.InitNodes(addrs[:2]); err != nil {
+		t.Fatalf("InitNodes: %v", err)
+	}
+	files := writeVideos(t, svc, 30)
+	if _, err := svc.AddNode(ctx, &proto.AddNodeRequest{NodeAddress: addrs[2], Weight: 3}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+	svc.mutex.RLock()
+	nodes := svc.nodeList()
+	svc.mutex.RUnlock()
+	if want := addrs[2] + "=3"; !slices.Contains(nodes, want) {
+		t.Fatalf("node list %v does not contain %v", nodes, want)
+	}
+
+	restarted := NewNetworkVideoContentService(2, 16)
+	if err := restarted.InitNodes(nodes); err != nil {
+		t.Fatalf("InitNodes after restart: %v", err)
+	}
+	checkPlacement(t, restarted, addrs, dirs, files)
+}
+
+func TestAddAndRemoveNodeWithReplicas(t *testing.T) {
+	addrs, dirs := startStorageNodes(t, 4)
+	ctx := context.Background()
+
+	svc := NewNetworkVideoContentService(2, 16)
+	if err := svc.InitNodes(addrs[:3]); err != nil {
+		t.Fatalf("InitNodes: %v", err)
+	}
+	files := writeVideos(t, svc, 30)
+
+	if _, err := svc.AddNode(ctx, &proto.AddNodeRequest{NodeAddress: addrs[3]}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+	checkPlacement(t, svc, addrs, dirs, files)
+
+	if _, err := svc.RemoveNode(ctx, &proto.RemoveNodeRequest{NodeAddress: addrs[0]}); err != nil {
+		t.Fatalf("RemoveNode: %v", err)
+	}
+	if _, ok := svc.nodes[addrs[0]]; ok {
+		t.Errorf("removed node %v is still in use", addrs[0])
+	}
+	checkPlacement(t, svc, addrs, dirs, files)
+}
+
+// TestAbortedRebalanceKeepsExistingCopies adds a node that already holds the
+// only copies of some files while another file cannot be migrated. The
+// rebalance is aborted, which must only undo the copies it made.
+func TestAbortedRebalanceKeepsExistingCopies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the migration retries")
+	}
+	addrs, dirs := startStorageNodes(t, 2)
+	ctx := context.Background()
+
+	svc := NewNetworkVideoContentService(1, 16)
+	if err := svc.InitNodes(addrs[:1]); err != nil {
+		t.Fatalf("InitNodes: %v", err)
+	}
+	after := svc.hashRing.clone()
+	after.addNode(addrs[1], 1)
+	movesToNew := func(key string) bool {
+		owners, _ := after.getNodes(key, 1)
+		return owners[0] == addrs[1]
+	}
+	// keysMovingToNew returns n keys with the given prefix that the new node
+	// will own.
+	keysMovingToNew := func(prefix string, n int) []string {
+		var keys []string
+		for i := 0; len(keys) < n; i++ {
+			if key := fmt.Sprintf("%v%d/manifest.mpd", prefix, i); movesToNew(key) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+
+	// Files on the old node that the rebalance copies to the new one.
+	copied := keysMovingToNew("copied", 3)
+	for _, key := range copied {
+		videoId, filename := filepath.Split(key)
+		if err := svc.Write(filepath.Clean(videoId), filename, []byte(key)); err != nil {
+			t.Fatalf("write %v: %v", key, err)
+		}
+	}
+	// Files whose only copy is on the new node.
+	existing := keysMovingToNew("existing", 3)
+	newNode := storageClient(t, addrs[1])
+	for _, key := range existing {
+		if _, err := storeFile(ctx, newNode, key, bytes.NewReader([]byte(key))); err != nil {
+			t.Fatalf("store %v: %v", key, err)
+		}
+	}
+	// An indexed key that no node holds, so its migration fails.
+	missing := keysMovingToNew("missing", 1)[0]
+	svc.mutex.Lock()
+	svc.allKeys[missing] = struct{}{}
+	svc.mutex.Unlock()
+
+	_, err := svc.AddNode(ctx, &proto.AddNodeRequest{NodeAddress: addrs[1]})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("AddNode error = %v, want FailedPrecondition", err)
+	}
+	if _, ok := svc.nodes[addrs[1]]; ok {
+		t.Errorf("node %v was added despite the failed migration", addrs[1])
+	}
+
+	for _, key := range existing {
+		if !onDisk(dirs[1], key) {
+			t.Errorf("%v was deleted from the only node holding it", key)
+		}
+	}
+	for _, key := range copied {
+		if !onDisk(dirs[0], key) {
+			t.Errorf("%v was deleted from its owner", key)
+		}
+		if onDisk(dirs[1], key) {
+			t.Errorf("copy of %v on the new node was not cleaned up", key)
+		}
+	}
+}
+
+// countingClient counts the ListKeys calls made to a storage node.
+type countingClient struct {
+	proto.VideoContentStorageServiceClient
+	listKeys atomic.Int32
+}
+
+func (c *countingClient) ListKeys(ctx context.Context, in *proto.ListKeysRequest, opts ...grpc.CallOption) (*proto.ListKeysResponse, error) {
+	c.listKeys.Add(1)
+	return c.VideoContentStorageServiceClient.ListKeys(ctx, in, opts...)
+}
+
+func TestMigrateListsEachNewOwnerOnce(t *testing.T) {
+	addrs, dirs := startStorageNodes(t, 2)
+	ctx := context.Background()
+
+	svc := NewNetworkVideoContentService(1, 16)
+	if err := svc.InitNodes(addrs[:1]); err != nil {
+		t.Fatalf("InitNodes: %v", err)
+	}
+	files := writeVideos(t, svc, 30)
+	after := svc.hashRing.clone()
+	after.addNode(addrs[1], 1)
+
+	newNode := &countingClient{VideoContentStorageServiceClient: storageClient(t, addrs[1])}
+	clients := map[string]proto.VideoContentStorageServiceClient{
+		addrs[0]: storageClient(t, addrs[0]),
+		addrs[1]: newNode,
+	}
+	rb := newRebalance(addrs[1], nodeJoining)
+	keys := slices.Sorted(maps.Keys(files))
+	if failed := svc.migrate(ctx, rb, clients, keys, svc.hashRing, after); len(failed) > 0 {
+		t.Fatalf("migrate failed: %v", failed)
+	}
+	if rb.migrated() == 0 {
+		t.Fatalf("no keys were migrated")
+	}
+	if n := newNode.listKeys.Load(); n != 1 {
+		t.Errorf("new node was listed %d times, want 1", n)
+	}
+
+	for key := range files {
+		owners, err := after.getNodes(key, 1)
+		if err != nil {
+			t.Fatalf("owners of %v: %v", key, err)
+		}
+		for i, addr := range addrs {
+			if owned, stored := owners[0] == addr, onDisk(dirs[i], key); owned != stored {
+				t.Errorf("%v on node %d: stored %v, owned %v", key, i, stored, owned)
+			}
+		}
+	}
+}
@@ -3,25 +3,66 @@
 package web
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ServerOptions configures the web server. Zero values select the defaults.
+type ServerOptions struct {
+	// TranscodeWorkers is the number of uploads transcoded concurrently.
+	TranscodeWorkers int
+	// TranscodeQueueSize is the number of uploads that may wait for a worker.
+	TranscodeQueueSize int
+	// Ladder is the set of renditions each upload is transcoded to, ordered
+	// by height. Renditions taller than the source are skipped.
+	Ladder []Rendition
+	// PosterOffset is the position in the video the poster image is taken
+	// from. Videos shorter than that use the frame at half their duration.
+	PosterOffset time.Duration
+	// UploadDir is the staging directory of resumable uploads, a directory
+	// under os.TempDir by default.
+	UploadDir string
+}
+
+const (
+	defaultTranscodeWorkers   = 2
+	defaultTranscodeQueueSize = 16
+	defaultPosterOffset       = 5 * time.Second
+)
+
+var errVideoExists = errors.New("video ID already exists")
+
 type server struct {
 	Addr string
 	Port int
 
 	metadataService VideoMetadataService
 	contentService  VideoContentService
+	jobs            *jobQueue
+	ladder          []Rendition
+	posterOffset    time.Duration
+
+	// contentMutex guards contentClaims, which holds the content hashes
+	// being transcoded.
+	contentMutex  sync.Mutex
+	contentClaims map[string]chan struct{}
+
+	uploads *uploadStore
 
 	mux *http.ServeMux
 }
@@ -29,138 +70,288 @@ type server struct {
 func NewServer(
 	metadataService VideoMetadataService,
 	contentService VideoContentService,
+	opts ServerOptions,
 ) *server {
-	return &server{
+	if opts.TranscodeWorkers <= 0 {
+		opts.TranscodeWorkers = defaultTranscodeWorkers
+	}
+	if opts.TranscodeQueueSize <= 0 {
+		opts.TranscodeQueueSize = defaultTranscodeQueueSize
+	}
+	if len(opts.Ladder) == 0 {
+		opts.Ladder = DefaultLadder
+	}
+	if opts.PosterOffset <= 0 {
+		opts.PosterOffset = defaultPosterOffset
+	}
+	if opts.UploadDir == "" {
+		opts.UploadDir = filepath.Join(os.TempDir(), "tritontube-uploads")
+	}
+
+	s := &server{
 		metadataService: metadataService,
 		contentService:  contentService,
+		ladder:          opts.Ladder,
+		posterOffset:    opts.PosterOffset,
+		contentClaims:   make(map[string]chan struct{}),
+		uploads:         newUploadStore(opts.UploadDir),
 	}
+	s.jobs = newJobQueue(opts.TranscodeWorkers, opts.TranscodeQueueSize, s.transcode)
+	return s
 }
 
 func (s *server) Start(lis net.Listener) error {
+	s.jobs.start()
+	s.uploads.prune()
+
 	s.mux = http.NewServeMux()
 	s.mux.HandleFunc("/upload", s.handleUpload)
+	s.mux.HandleFunc(strings.TrimSuffix(uploadsPath, "/"), s.handleTus)
+	s.mux.HandleFunc(uploadsPath, s.handleTus)
+	s.mux.HandleFunc("/jobs/", s.handleJob)
 	s.mux.HandleFunc("/videos/", s.handleVideo)
 	s.mux.HandleFunc("/content/", s.handleVideoContent)
+	s.registerAPI(s.mux)
 	s.mux.HandleFunc("/", s.handleIndex)
 
 	return http.Serve(lis, s.mux)
 }
 
+var templateFuncs = template.FuncMap{
+	"duration": formatDuration,
+	"fileSize": formatFileSize,
+}
+
 var (
-	indexTmpl = template.Must(template.New("index").Parse(indexHTML))
-	videoTmpl = template.Must(template.New("video").Parse(videoHTML))
+	indexTmpl = template.Must(template.New("index").Funcs(templateFuncs).Parse(indexHTML))
+	videoTmpl = template.Must(template.New("video").Funcs(templateFuncs).Parse(videoHTML))
 )
 
+// formatDuration formats d as m:ss, or h:mm:ss for an hour or more.
+func formatDuration(d time.Duration) string {
+	secs := int(d.Round(time.Second).Seconds())
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// formatFileSize formats n bytes with a binary unit.
+func formatFileSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// indexPageSize is the number of videos listed per index page.
+const indexPageSize = 24
+
+// videoQueryFrom reads a VideoQuery from the q, sort, order and page_token
+// parameters of r.
+func videoQueryFrom(r *http.Request) VideoQuery {
+	params := r.URL.Query()
+	return VideoQuery{
+		Text:      strings.TrimSpace(params.Get("q")),
+		SortBy:    params.Get("sort"),
+		Ascending: params.Get("order") == "asc",
+		Cursor:    params.Get("page_token"),
+	}
+}
+
+// pageURL returns the URL of the page of query at cursor, keeping the other
+// parameters of r.
+func pageURL(r *http.Request, cursor string) string {
+	params := r.URL.Query()
+	params.Set("page_token", cursor)
+	return r.URL.Path + "?" + params.Encode()
+}
+
 func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	videos, err := s.metadataService.List()
+	query := videoQueryFrom(r)
+	query.Limit = indexPageSize
+	page, err := s.metadataService.List(query)
+	if errors.Is(err, ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to read video list", http.StatusInternalServerError)
 		return
 	}
-	if err := indexTmpl.Execute(w, videos); err != nil {
+	data := struct {
+		Videos     []VideoMetadata
+		Jobs       []transcodeJob
+		Processing bool
+		Query      VideoQuery
+		NextURL    string
+		PrevURL    string
+	}{Videos: page.Videos, Jobs: s.jobs.pending(), Query: query}
+	if page.NextCursor != "" {
+		data.NextURL = pageURL(r, page.NextCursor)
+	}
+	if page.PrevCursor != "" {
+		data.PrevURL = pageURL(r, page.PrevCursor)
+	}
+	for _, job := range data.Jobs {
+		if job.State == jobQueued || job.State == jobRunning {
+			data.Processing = true
+		}
+	}
+	if err := indexTmpl.Execute(w, data); err != nil {
 		log.Println("Template execute error: ", err)
 	}
 }
 
-func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(10 << 30); err != nil {
-		http.Error(w, "Could not parse form", http.StatusBadRequest)
-		return
+// statusError is a request failure and the HTTP status it is reported with.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// errorStatus returns the HTTP status and message to report err with.
+func errorStatus(err error) (int, string) {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code, se.msg
+	}
+	return http.StatusInternalServerError, err.Error()
+}
+
+// enqueueUpload saves the file uploaded in the request's "file" form field and
+// queues it for transcoding, along with the optional "title" and "description"
+// fields. The video ID is the file name without extension.
+func (s *server) enqueueUpload(r *http.Request) (*transcodeJob, error) {
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return nil, &statusError{http.StatusBadRequest, "Could not parse form"}
 	}
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "Failed to get file", http.StatusBadRequest)
-		return
+		return nil, &statusError{http.StatusBadRequest, "Failed to get file"}
 	}
 	defer file.Close()
 
 	filename := header.Filename
-	videoId := strings.TrimSuffix(filename, filepath.Ext(filename))
-	if _, err := s.metadataService.Read(videoId); err == nil {
-		http.Error(w, "Video ID already exists", http.StatusConflict)
-		return
-	} else if !strings.Contains(err.Error(), "no rows in result set") {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	videoId := videoIdOf(filename)
+	if err := s.checkVideoIdFree(videoId); err != nil {
+		return nil, err
 	}
 
-	tmpDir := os.TempDir()
-	tmpIn := filepath.Join(tmpDir, filename)
-	outDir := filepath.Join(tmpDir, videoId)
-	if err := os.MkdirAll(outDir, 0755); err != nil {
-		http.Error(w, "Failed to create tmp dir", http.StatusInternalServerError)
-		return
+	f, err := os.CreateTemp("", "tritontube-upload-*"+filepath.Ext(filename))
+	if err != nil {
+		return nil, &statusError{http.StatusInternalServerError, "Failed to save tmp file"}
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), file)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, &statusError{http.StatusInternalServerError, "Failed to write to tmp file"}
 	}
+	f.Close()
 
-	f, err := os.Create(tmpIn)
+	meta := VideoMetadata{
+		Id:               videoId,
+		Title:            strings.TrimSpace(r.FormValue("title")),
+		Description:      strings.TrimSpace(r.FormValue("description")),
+		OriginalSize:     size,
+		OriginalFilename: filename,
+		ContentHash:      hex.EncodeToString(hash.Sum(nil)),
+	}
+	job, err := s.queueTranscode(meta, f.Name())
 	if err != nil {
-		http.Error(w, "Failed to save tmp file", http.StatusInternalServerError)
-		return
+		os.Remove(f.Name())
+		return nil, err
 	}
-	defer f.Close()
-	if _, err := io.Copy(f, file); err != nil {
-		http.Error(w, "Failed to write to tmp file", http.StatusInternalServerError)
-		return
+	return job, nil
+}
+
+// videoIdOf returns the ID of a video uploaded as filename.
+func videoIdOf(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename))
+}
+
+// checkVideoIdFree fails with a Conflict status if videoId is taken.
+func (s *server) checkVideoIdFree(videoId string) error {
+	if _, err := s.metadataService.Read(videoId); err == nil {
+		return &statusError{http.StatusConflict, "Video ID already exists"}
+	} else if !errors.Is(err, ErrVideoNotFound) {
+		return err
 	}
+	return nil
+}
 
-	manifestPath := filepath.Join(outDir, "manifest.mpd")
-
-	cmd := exec.Command("ffmpeg",
-		"-i", tmpIn, // input file
-		"-c:v", "libx264", // video codec
-		"-c:a", "aac", // audio codec
-		"-bf", "1", // max 1 b-frame
-		"-keyint_min", "120", // minimum keyframe interval
-		"-g", "120", // keyframe every 120 frames
-		"-sc_threshold", "0", // scene change threshold
-		"-b:v", "3000k", // video bitrate
-		"-b:a", "128k", // audio bitrate
-		"-f", "dash", // dash format
-		"-use_timeline", "1", // use timeline
-		"-use_template", "1", // use template
-		"-init_seg_name", "init-$RepresentationID$.m4s", // init segment naming
-		"-media_seg_name", "chunk-$RepresentationID$-$Number%05d$.m4s", // media segment naming
-		"-seg_duration", "4", // segment duration in seconds
-		manifestPath) // output file
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Println("ffmpeg output: ", string(out))
-		http.Error(w, "FFmpeg conversion failed", http.StatusInternalServerError)
-		return
+// queueTranscode queues the uploaded file at path for transcoding into the
+// video described by meta.
+func (s *server) queueTranscode(meta VideoMetadata, path string) (*transcodeJob, error) {
+	job, err := s.jobs.enqueue(meta, path)
+	if errors.Is(err, errVideoExists) {
+		return nil, &statusError{http.StatusConflict, "Video ID already exists"}
+	} else if errors.Is(err, errQueueFull) {
+		return nil, &statusError{http.StatusServiceUnavailable, "Too many uploads are being processed, try again later"}
+	} else if err != nil {
+		return nil, err
 	}
+	return job, nil
+}
 
-	entries, err := os.ReadDir(outDir)
+func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	job, err := s.enqueueUpload(r)
 	if err != nil {
-		http.Error(w, "Failed to read out dir", http.StatusInternalServerError)
+		code, msg := errorStatus(err)
+		if code == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "60")
+		}
+		http.Error(w, msg, code)
 		return
 	}
-	for _, ent := range entries {
-		if ent.IsDir() {
-			continue
-		}
-		data, err := os.ReadFile(filepath.Join(outDir, ent.Name()))
-		if err != nil {
-			http.Error(w, "Failed to read segment file", http.StatusInternalServerError)
-			fmt.Printf("Read failed: %v", err)
-			return
-		}
-		if err := s.contentService.Write(videoId, ent.Name(), data); err != nil {
-			http.Error(w, "Failed to write segment file", http.StatusInternalServerError)
-			fmt.Printf("Write failed: %v", err)
-			return
-		}
+
+	// Browsers submitting the upload form go back to the index, which lists
+	// videos that are still processing.
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Location", "/")
+		w.WriteHeader(http.StatusSeeOther)
+		return
 	}
+	w.Header().Set("Location", "/jobs/"+job.Id)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	jobId := r.URL.Path[len("/jobs/"):]
 
-	if err := s.metadataService.Create(videoId, time.Now()); err != nil {
-		http.Error(w, "Failed to write metadata", http.StatusInternalServerError)
+	job, ok := s.jobs.get(jobId)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
 		return
 	}
+	writeJSON(w, http.StatusOK, job)
+}
 
-	w.Header().Set("Location", "/")
-	w.WriteHeader(http.StatusSeeOther)
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("JSON encode error: ", err)
+	}
 }
 
 func (s *server) handleVideo(w http.ResponseWriter, r *http.Request) {
 	videoId := r.URL.Path[len("/videos/"):]
+	if r.Method == http.MethodDelete {
+		s.handleDeleteVideo(w, videoId)
+		return
+	}
 	log.Println("GET Video ID:", videoId)
 
 	meta, err := s.metadataService.Read(videoId)
@@ -173,6 +364,40 @@ func (s *server) handleVideo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *server) handleDeleteVideo(w http.ResponseWriter, videoId string) {
+	log.Println("DELETE Video ID:", videoId)
+
+	if err := s.deleteVideo(videoId); err != nil {
+		code, msg := errorStatus(err)
+		http.Error(w, msg, code)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteVideo removes the video's metadata and then its content, unless other
+// videos share it. The metadata service drops the video's reference to the
+// content as it deletes the video, so no new video can share content that is
+// about to be deleted.
+func (s *server) deleteVideo(videoId string) error {
+	if _, err := s.metadataService.Read(videoId); err != nil {
+		return &statusError{http.StatusNotFound, "Video not found"}
+	}
+	contentId, err := s.metadataService.Delete(videoId)
+	if err != nil {
+		log.Println("Delete metadata error: ", err)
+		return &statusError{http.StatusInternalServerError, "Failed to delete video metadata"}
+	}
+	if contentId == "" {
+		return nil
+	}
+	// The video is gone either way; content left behind only takes space.
+	if err := s.contentService.Delete(contentId); err != nil {
+		log.Printf("Delete content %v of %v failed: %v", contentId, videoId, err)
+	}
+	return nil
+}
+
 func (s *server) handleVideoContent(w http.ResponseWriter, r *http.Request) {
 	// parse /content/<videoId>/<filename>
 	videoId := r.URL.Path[len("/content/"):]
@@ -185,18 +410,66 @@ func (s *server) handleVideoContent(w http.ResponseWriter, r *http.Request) {
 	filename := parts[1]
 	log.Println("GET Video ID:", videoId, ",Filename:", filename)
 
-	data, err := s.contentService.Read(videoId, filename)
-	if err != nil {
-		http.Error(w, "Content not found", http.StatusInternalServerError)
+	// Content is immutable after upload, so the upload time serves as the
+	// modification time for If-Modified-Since.
+	contentId := videoId
+	var modTime time.Time
+	if meta, err := s.metadataService.Read(videoId); err == nil {
+		contentId = contentIdOf(meta)
+		modTime = meta.UploadedAt
+	}
+
+	content, size, err := openContent(s.contentService, contentId, filename)
+	switch {
+	case errors.Is(err, ErrContentNotFound):
+		http.Error(w, "Content not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrContentUnavailable):
+		http.Error(w, "Content temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("Open content %v/%v failed: %v", contentId, filename, err)
+		http.Error(w, "Failed to read content", http.StatusInternalServerError)
 		return
 	}
+	defer content.Close()
 
 	ext := filepath.Ext(filename)
 	switch strings.ToLower(ext) {
 	case ".mpd":
 		w.Header().Set("Content-Type", "application/dash+xml")
+		w.Header().Set("Cache-Control", "no-cache")
+	case ".m3u8":
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+	case ".jpg":
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
 	case ".m4s":
 		w.Header().Set("Content-Type", "video/iso.segment")
+		// Segments never change once uploaded.
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		// Keep ServeContent from sniffing, which would need to seek back.
+		w.Header().Set("Content-Type", cmp.Or(mime.TypeByExtension(ext), "application/octet-stream"))
+	}
+
+	// The file is streamed, so its ETag cannot depend on the data. Content
+	// never changes under a content ID, and a video ID reused after a delete
+	// has a new upload time.
+	sum := sha256.Sum256(fmt.Appendf(nil, "%v/%v:%d:%d", contentId, filename, size, modTime.UnixNano()))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	rs, ok := content.(io.ReadSeeker)
+	if !ok {
+		// A stream cannot seek back for each part of a multipart response,
+		// so several ranges are answered with the whole file.
+		if strings.Contains(r.Header.Get("Range"), ",") {
+			r.Header.Del("Range")
+		}
+		rs = &forwardSeeker{r: content, size: size}
 	}
-	w.Write(data)
+	// ServeContent handles Range, If-None-Match and If-Modified-Since, and
+	// sets Content-Length and Last-Modified.
+	http.ServeContent(w, r, filename, modTime, rs)
 }
@@ -4,7 +4,9 @@ package web
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -29,65 +31,192 @@ func NewSQLiteVideoMetadataService(dsn string) (*SQLiteVideoMetadataService, err
 		return nil, fmt.Errorf("set busy timeout failed: %v", err)
 	}
 
-	createTable := `CREATE TABLE IF NOT EXISTS videos (
-		id TEXT PRIMARY KEY,
-		uploaded_at DATETIME NOT NULL
-	);
-	`
-	if _, err := db.Exec(createTable); err != nil {
+	if err := migrate(db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("Create table failed: %v", err)
+		return nil, err
 	}
 	return &SQLiteVideoMetadataService{db: db}, nil
 }
 
-func (s *SQLiteVideoMetadataService) Create(videoId string, uploadedAt time.Time) error {
-	insert := `INSERT INTO videos (id, uploaded_at) VALUES (?, ?);`
-	_, err := s.db.Exec(insert, videoId, uploadedAt.UTC().Format(time.RFC3339))
+const metadataSelect = `SELECT id, uploaded_at, title, description, duration_seconds, width, height,
+	video_codec, audio_codec, frame_rate, original_size, original_filename, content_hash, content_id`
+
+func (s *SQLiteVideoMetadataService) Create(meta VideoMetadata) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin insert metadata failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	insert := `INSERT INTO videos (id, uploaded_at, title, description, duration_seconds, width, height,
+		video_codec, audio_codec, frame_rate, original_size, original_filename, content_hash, content_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err = tx.Exec(insert, meta.Id, meta.UploadedAt.UTC().Format(time.RFC3339),
+		meta.Title, meta.Description, meta.Duration.Seconds(), meta.Width, meta.Height,
+		meta.VideoCodec, meta.AudioCodec, meta.FrameRate, meta.OriginalSize, meta.OriginalFilename,
+		meta.ContentHash, contentIdOf(&meta))
 	if err != nil {
 		return fmt.Errorf("insert metadate failed: %v", err)
 	}
+
+	// Deleted content keeps its row with refs 0, which the update skips.
+	upsert := `INSERT INTO content_refs (content_id, refs) VALUES (?, 1)
+		ON CONFLICT (content_id) DO UPDATE SET refs = refs + 1 WHERE refs > 0;`
+	res, err := tx.Exec(upsert, contentIdOf(&meta))
+	if err != nil {
+		return fmt.Errorf("update content references failed: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("update content references failed: %v", err)
+	} else if n == 0 {
+		return fmt.Errorf("insert metadate failed: %w", errContentDeleted)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit metadata failed: %v", err)
+	}
 	return nil
 }
 
-func (s *SQLiteVideoMetadataService) List() ([]VideoMetadata, error) {
-	slct := `SELECT id, uploaded_at FROM videos ORDER BY uploaded_at DESC;`
-	rows, err := s.db.Query(slct)
+// scanMetadata scans a row selected with metadataSelect, followed by the
+// columns scanned into extra.
+func scanMetadata(row interface{ Scan(dest ...any) error }, extra ...any) (*VideoMetadata, error) {
+	var meta VideoMetadata
+	var uploadedAtStr string
+	var durationSeconds float64
+	dest := []any{&meta.Id, &uploadedAtStr, &meta.Title, &meta.Description, &durationSeconds,
+		&meta.Width, &meta.Height, &meta.VideoCodec, &meta.AudioCodec, &meta.FrameRate,
+		&meta.OriginalSize, &meta.OriginalFilename, &meta.ContentHash, &meta.ContentId}
+	err := row.Scan(append(dest, extra...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan metadata failed: %v", err)
+	}
+	ts, err := time.Parse(time.RFC3339, uploadedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse uploaded time failed: %v", uploadedAtStr)
+	}
+	meta.UploadedAt = ts
+	meta.Duration = time.Duration(durationSeconds * float64(time.Second))
+	return &meta, nil
+}
+
+// sortExprs are the SQL expressions videos are ordered by for each sort field.
+var sortExprs = map[string]string{
+	SortByUploadedAt: "uploaded_at",
+	SortByTitle:      "lower(CASE WHEN title = '' THEN id ELSE title END)",
+	SortByDuration:   "duration_seconds",
+}
+
+// likeEscaper escapes the LIKE wildcards in a search text.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (s *SQLiteVideoMetadataService) List(query VideoQuery) (*VideoPage, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+	c, err := decodeCursor(query)
+	if err != nil {
+		return nil, err
+	}
+
+	sortExpr := sortExprs[query.SortBy]
+	var where []string
+	var args []any
+	if query.Text != "" {
+		where = append(where, `(id LIKE ? ESCAPE '\' OR title LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`)
+		pattern := "%" + likeEscaper.Replace(query.Text) + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	// Pages after a cursor continue in the query's order; pages before it
+	// are fetched in reverse order starting at the cursor.
+	ascending := query.Ascending
+	if c != nil && c.Backward {
+		ascending = !ascending
+	}
+	dir, cmp := "DESC", "<"
+	if ascending {
+		dir, cmp = "ASC", ">"
+	}
+	if c != nil {
+		where = append(where, fmt.Sprintf("(%s, id) %s (?, ?)", sortExpr, cmp))
+		args = append(args, c.Value, c.Id)
+	}
+
+	slct := metadataSelect + ", " + sortExpr + " FROM videos"
+	if len(where) > 0 {
+		slct += " WHERE " + strings.Join(where, " AND ")
+	}
+	slct += fmt.Sprintf(" ORDER BY %s %s, id %s", sortExpr, dir, dir)
+	if query.Limit > 0 {
+		slct += " LIMIT ?"
+		args = append(args, query.Limit+1)
+	}
+
+	rows, err := s.db.Query(slct+";", args...)
 	if err != nil {
 		return nil, fmt.Errorf("query metadate failed: %v", err)
 	}
 	defer rows.Close()
 
 	var metadataList []VideoMetadata
+	var keys []any
 	for rows.Next() {
-		var id string
-		var uploadedAtStr string
-		if err := rows.Scan(&id, &uploadedAtStr); err != nil {
-			return nil, fmt.Errorf("scan metadata failed: %v", err)
-		}
-		ts, err := time.Parse(time.RFC3339, uploadedAtStr)
+		var key any
+		meta, err := scanMetadata(rows, &key)
 		if err != nil {
-			return nil, fmt.Errorf("parse uploaded time failed: %v", uploadedAtStr)
+			return nil, err
 		}
-		metadataList = append(metadataList, VideoMetadata{Id: id, UploadedAt: ts})
+		metadataList = append(metadataList, *meta)
+		keys = append(keys, key)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows iteration error: %v", err)
 	}
-	return metadataList, nil
+	return pageOf(query, c, metadataList, keys), nil
 }
 
 func (s *SQLiteVideoMetadataService) Read(videoId string) (*VideoMetadata, error) {
-	slct := `SELECT id, uploaded_at FROM videos WHERE id = ?;`
-	row := s.db.QueryRow(slct, videoId)
-	var id string
-	var uploadedAtStr string
-	if err := row.Scan(&id, &uploadedAtStr); err != nil {
-		return nil, fmt.Errorf("scan metadata failed: %v", err)
-	}
-	ts, err := time.Parse(time.RFC3339, uploadedAtStr)
+	slct := metadataSelect + ` FROM videos WHERE id = ?;`
+	return scanMetadata(s.db.QueryRow(slct, videoId))
+}
+
+func (s *SQLiteVideoMetadataService) FindByContentHash(hash string) (*VideoMetadata, error) {
+	slct := metadataSelect + ` FROM videos WHERE content_hash = ? LIMIT 1;`
+	return scanMetadata(s.db.QueryRow(slct, hash))
+}
+
+func (s *SQLiteVideoMetadataService) Delete(videoId string) (string, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("parse uploaded time failed: %v", uploadedAtStr)
+		return "", fmt.Errorf("begin delete metadata failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	var contentId string
+	del := `DELETE FROM videos WHERE id = ? RETURNING content_id;`
+	if err := tx.QueryRow(del, videoId).Scan(&contentId); errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("delete metadata failed: %v", err)
+	}
+
+	var refs int
+	update := `UPDATE content_refs SET refs = refs - 1 WHERE content_id = ? AND refs > 0 RETURNING refs;`
+	if err := tx.QueryRow(update, contentId).Scan(&refs); errors.Is(err, sql.ErrNoRows) {
+		// The content is not counted, so it may still be used.
+		refs = 1
+	} else if err != nil {
+		return "", fmt.Errorf("update content references failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit delete metadata failed: %v", err)
+	}
+	if refs > 0 {
+		return "", nil
 	}
-	return &VideoMetadata{Id: id, UploadedAt: ts}, nil
+	return contentId, nil
 }
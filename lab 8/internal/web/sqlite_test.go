@@ -0,0 +1,120 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestSQLiteService returns a service on a new database holding videos.
+func newTestSQLiteService(t *testing.T, videos []VideoMetadata) *SQLiteVideoMetadataService {
+	t.Helper()
+	s, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	for _, meta := range videos {
+		if err := s.Create(meta); err != nil {
+			t.Fatalf("Create %v: %v", meta.Id, err)
+		}
+	}
+	return s
+}
+
+func TestSQLiteListPages(t *testing.T) {
+	// Every sort field has ties, which the ID decides: b and c have the same
+	// upload time and the same title but for case, and durations pair up.
+	// d is untitled and sorts by its ID.
+	base := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestSQLiteService(t, []VideoMetadata{
+		{Id: "a", Title: "banana", UploadedAt: base, Duration: 3 * time.Second},
+		{Id: "b", Title: "Apple", UploadedAt: base.Add(time.Hour), Duration: time.Second},
+		{Id: "c", Title: "apple", UploadedAt: base.Add(time.Hour), Duration: 2 * time.Second},
+		{Id: "d", Title: "", UploadedAt: base.Add(2 * time.Hour), Duration: 2 * time.Second},
+		{Id: "e", Title: "Cherry", UploadedAt: base.Add(2 * time.Hour), Duration: time.Second},
+	})
+
+	tests := []struct {
+		sortBy    string
+		ascending bool
+		limit     int
+		want      [][]string
+	}{
+		{SortByUploadedAt, true, 2, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{SortByUploadedAt, false, 2, [][]string{{"e", "d"}, {"c", "b"}, {"a"}}},
+		{SortByTitle, true, 2, [][]string{{"b", "c"}, {"a", "e"}, {"d"}}},
+		{SortByTitle, false, 2, [][]string{{"d", "e"}, {"a", "c"}, {"b"}}},
+		{SortByDuration, true, 2, [][]string{{"b", "e"}, {"c", "d"}, {"a"}}},
+		{SortByDuration, false, 2, [][]string{{"a", "d"}, {"c", "e"}, {"b"}}},
+		{SortByDuration, true, 3, [][]string{{"b", "e", "c"}, {"d", "a"}}},
+		{SortByDuration, true, 5, [][]string{{"b", "e", "c", "d", "a"}}},
+		{SortByDuration, true, 0, [][]string{{"b", "e", "c", "d", "a"}}},
+		{"", false, 2, [][]string{{"e", "d"}, {"c", "b"}, {"a"}}},
+	}
+	for _, tt := range tests {
+		query := VideoQuery{SortBy: tt.sortBy, Ascending: tt.ascending, Limit: tt.limit}
+		t.Run(fmt.Sprintf("%v ascending=%v limit=%d", tt.sortBy, tt.ascending, tt.limit), func(t *testing.T) {
+			forward, backward := listPages(t, s, query)
+			if !slices.EqualFunc(forward, tt.want, slices.Equal) {
+				t.Errorf("pages forward = %v, want %v", forward, tt.want)
+			}
+			// Paging backward starts from the last page.
+			if want := tt.want[:len(tt.want)-1]; !slices.EqualFunc(backward, want, slices.Equal) {
+				t.Errorf("pages backward = %v, want %v", backward, want)
+			}
+		})
+	}
+}
+
+func TestSQLiteListRejectsMalformedCursor(t *testing.T) {
+	s := newTestSQLiteService(t, []VideoMetadata{
+		{Id: "a", Title: "banana"},
+		{Id: "b", Title: "apple"},
+	})
+	titleQuery := VideoQuery{SortBy: SortByTitle, Limit: 1}
+	page, err := s.List(titleQuery)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		query  VideoQuery
+		cursor string
+	}{
+		{"not base64", titleQuery, "bogus!"},
+		{"not json", titleQuery, base64.RawURLEncoding.EncodeToString([]byte("bogus"))},
+		{"other sort field", VideoQuery{SortBy: SortByDuration, Limit: 1}, page.NextCursor},
+		{"other direction", VideoQuery{SortBy: SortByTitle, Ascending: true, Limit: 1}, page.NextCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			query.Cursor = tt.cursor
+			if _, err := s.List(query); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("List error = %v, want ErrInvalidQuery", err)
+			}
+
+			// The API reports it as a bad request.
+			srv := NewServer(s, failingContentService{}, ServerOptions{UploadDir: t.TempDir()})
+			mux := http.NewServeMux()
+			srv.registerAPI(mux)
+			params := url.Values{"sort": {query.SortBy}, "page_token": {tt.cursor}}
+			if query.Ascending {
+				params.Set("order", "asc")
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, apiPrefix+"/videos?"+params.Encode(), nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("API status = %v, want 400", rec.Code)
+			}
+		})
+	}
+}
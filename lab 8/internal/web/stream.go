@@ -0,0 +1,131 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// openContent opens a file of svc for reading, streaming it if svc supports
+// that and reading it whole otherwise.
+func openContent(svc VideoContentService, videoId string, filename string) (io.ReadCloser, int64, error) {
+	if ss, ok := svc.(StreamingVideoContentService); ok {
+		return ss.Open(videoId, filename)
+	}
+	data, err := svc.Read(videoId, filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytesReadCloser{bytes.NewReader(data)}, int64(len(data)), nil
+}
+
+// bytesReadCloser is a file held in memory. Unlike io.NopCloser, it keeps the
+// io.Seeker of the bytes.Reader.
+type bytesReadCloser struct {
+	*bytes.Reader
+}
+
+func (bytesReadCloser) Close() error {
+	return nil
+}
+
+// createContent creates a file of svc for writing, streaming it if svc
+// supports that and buffering it until Close otherwise.
+func createContent(svc VideoContentService, videoId string, filename string) (io.WriteCloser, error) {
+	if ss, ok := svc.(StreamingVideoContentService); ok {
+		return ss.Create(videoId, filename)
+	}
+	return &bufferedWriter{svc: svc, videoId: videoId, filename: filename}, nil
+}
+
+// checkWritable returns an error if svc can tell that writing files would
+// fail, so that work whose output cannot be stored is not started.
+func checkWritable(svc VideoContentService) error {
+	if c, ok := svc.(interface{ CheckWritable() error }); ok {
+		return c.CheckWritable()
+	}
+	return nil
+}
+
+// closeContent closes a writer returned by createContent, discarding the
+// file instead if err is not nil.
+func closeContent(w io.WriteCloser, err error) error {
+	if err == nil {
+		return w.Close()
+	}
+	if a, ok := w.(interface{ CloseWithError(error) error }); ok {
+		a.CloseWithError(err)
+	}
+	return err
+}
+
+// bufferedWriter writes a file to a VideoContentService in one piece when
+// closed.
+type bufferedWriter struct {
+	svc      VideoContentService
+	videoId  string
+	filename string
+	buf      bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *bufferedWriter) Close() error {
+	return w.svc.Write(w.videoId, w.filename, w.buf.Bytes())
+}
+
+func (w *bufferedWriter) CloseWithError(err error) error {
+	w.buf.Reset()
+	return nil
+}
+
+// forwardSeeker adapts a stream of known size to the io.ReadSeeker that
+// http.ServeContent expects. Seeking only moves forward, by discarding data,
+// except that the size can be read by seeking to the end before reading.
+// That covers everything ServeContent does for a single range.
+type forwardSeeker struct {
+	r    io.Reader
+	size int64
+	// pos is the position in r; target is where the next Read starts.
+	pos    int64
+	target int64
+}
+
+var errSeekBackward = errors.New("cannot seek backward in a stream")
+
+func (s *forwardSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.target
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	s.target = offset
+	return offset, nil
+}
+
+func (s *forwardSeeker) Read(p []byte) (int, error) {
+	if s.target < s.pos {
+		return 0, errSeekBackward
+	}
+	if s.target > s.pos {
+		n, err := io.CopyN(io.Discard, s.r, s.target-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.r.Read(p)
+	s.pos += int64(n)
+	s.target = s.pos
+	return n, err
+}
@@ -8,24 +8,229 @@ const indexHTML = `
   <head>
     <meta charset="UTF-8" />
     <title>TritonTube</title>
+    {{if .Processing}}<noscript><meta http-equiv="refresh" content="5" /></noscript>{{end}}
   </head>
   <body>
     <h1>Welcome to TritonTube</h1>
     <h2>Upload an MP4 Video</h2>
-    <form action="/upload" method="post" enctype="multipart/form-data">
-      <input type="file" name="file" accept="video/mp4" required />
+    <form id="uploadForm" action="/upload" method="post" enctype="multipart/form-data">
+      <p><input type="file" name="file" accept="video/mp4" required /></p>
+      <p><input type="text" name="title" placeholder="Title" /></p>
+      <p><textarea name="description" placeholder="Description"></textarea></p>
       <input type="submit" value="Upload" />
     </form>
-    <h2>Watchlist</h2>
+    <p id="uploadStatus"></p>
+    <script>
+      // Uploads go through the resumable tus endpoint in chunks. Each upload
+      // URL is remembered per file, so after a dropped connection or a reload
+      // the upload continues from the last byte the server has. Without
+      // JavaScript the form falls back to a single multipart POST.
+      (function () {
+        var chunkSize = 8 << 20;
+        var maxRetryDelay = 30000;
+        var form = document.querySelector("#uploadForm");
+        var status = document.querySelector("#uploadStatus");
+        var storagePrefix = "tritontube-upload:";
+        var uploading = false;
+
+        // Refresh the progress of processing videos, unless that would
+        // interrupt an upload.
+        if ({{.Processing}}) {
+          setInterval(function () {
+            if (!uploading) {
+              location.reload();
+            }
+          }, 5000);
+        }
+
+        function storageKey(file) {
+          return storagePrefix + [file.name, file.size, file.lastModified].join(":");
+        }
+
+        function encodeMetadata(metadata) {
+          return Object.keys(metadata).map(function (key) {
+            var bytes = new TextEncoder().encode(metadata[key]);
+            var binary = "";
+            bytes.forEach(function (b) { binary += String.fromCharCode(b); });
+            return key + " " + btoa(binary);
+          }).join(",");
+        }
+
+        function tusFetch(url, method, headers, body) {
+          headers["Tus-Resumable"] = "1.0.0";
+          return fetch(url, { method: method, headers: headers, body: body, cache: "no-store" });
+        }
+
+        function sleep(ms) {
+          return new Promise(function (resolve) { setTimeout(resolve, ms); });
+        }
+
+        // offsetOf returns the number of bytes the server has of the upload at
+        // url, or -1 if it no longer exists.
+        async function offsetOf(url) {
+          var resp = await tusFetch(url, "HEAD", {});
+          if (resp.status === 404 || resp.status === 410) {
+            return -1;
+          }
+          if (!resp.ok) {
+            throw new Error("HTTP " + resp.status);
+          }
+          return parseInt(resp.headers.get("Upload-Offset"), 10);
+        }
+
+        async function createUpload(file, title, description) {
+          var resp = await tusFetch("/uploads/", "POST", {
+            "Upload-Length": String(file.size),
+            "Upload-Metadata": encodeMetadata({ filename: file.name, title: title, description: description }),
+          });
+          if (!resp.ok) {
+            throw new Error(await resp.text());
+          }
+          return new URL(resp.headers.get("Location"), location.href).href;
+        }
+
+        function showProgress(file, offset) {
+          var percent = file.size ? Math.floor((offset / file.size) * 100) : 100;
+          status.textContent = "Uploading " + file.name + ": " + percent + "%";
+        }
+
+        async function upload(file, title, description) {
+          var key = storageKey(file);
+          var url = localStorage.getItem(key);
+          var offset = -1;
+          if (url) {
+            offset = await offsetOf(url);
+          }
+          if (offset < 0) {
+            url = await createUpload(file, title, description);
+            localStorage.setItem(key, url);
+            offset = 0;
+          }
+
+          var delay = 1000;
+          while (true) {
+            showProgress(file, offset);
+            var resp = null;
+            try {
+              var end = Math.min(offset + chunkSize, file.size);
+              resp = await tusFetch(url, "PATCH", {
+                "Content-Type": "application/offset+octet-stream",
+                "Upload-Offset": String(offset),
+              }, file.slice(offset, end));
+              if (resp.status === 204) {
+                offset = parseInt(resp.headers.get("Upload-Offset"), 10);
+                delay = 1000;
+                if (offset === file.size) {
+                  break;
+                }
+                continue;
+              }
+              // Other client errors are final, except an offset mismatch,
+              // which is resolved by asking for the offset again.
+              if (resp.status >= 400 && resp.status < 500 && resp.status !== 409 && resp.status !== 423) {
+                throw new Error(await resp.text());
+              }
+            } catch (err) {
+              if (resp) {
+                localStorage.removeItem(key);
+                throw err;
+              }
+            }
+
+            // The connection dropped, the server failed or the upload is
+            // busy: wait, then continue from wherever the server got to.
+            var message = resp ? await resp.text() : "connection lost";
+            status.textContent = "Upload paused (" + message.trim() + "), retrying in " + delay / 1000 + "s";
+            await sleep(delay);
+            delay = Math.min(delay * 2, maxRetryDelay);
+            try {
+              offset = await offsetOf(url);
+            } catch (err) {
+              continue;
+            }
+            if (offset < 0) {
+              localStorage.removeItem(key);
+              throw new Error(resp && resp.status === 409 ? message : "the upload expired, please upload the file again");
+            }
+          }
+          localStorage.removeItem(key);
+        }
+
+        if (!window.fetch || !window.localStorage) {
+          return;
+        }
+        for (var i = 0; i < localStorage.length; i++) {
+          if (localStorage.key(i).indexOf(storagePrefix) === 0) {
+            var name = localStorage.key(i).slice(storagePrefix.length).split(":")[0];
+            status.textContent = "The upload of " + name + " was interrupted. Select the same file to resume it.";
+            break;
+          }
+        }
+
+        form.addEventListener("submit", function (event) {
+          var file = form.elements.file.files[0];
+          if (!file) {
+            return;
+          }
+          event.preventDefault();
+          uploading = true;
+          form.querySelector("input[type=submit]").disabled = true;
+          upload(file, form.elements.title.value, form.elements.description.value).then(function () {
+            status.textContent = "Upload of " + file.name + " complete.";
+            location.href = "/";
+          }, function (err) {
+            uploading = false;
+            status.textContent = "Upload of " + file.name + " failed: " + err.message;
+            form.querySelector("input[type=submit]").disabled = false;
+          });
+        });
+      })();
+    </script>
+    {{if .Jobs}}
+    <h2>Processing</h2>
     <ul>
-      {{range .}}
+      {{range .Jobs}}
       <li>
-        <a href="/videos/{{.Id}}">{{.Id}} ({{.UploadedAt}})</a>
+        {{.VideoId}}: {{.State}}{{if eq .State "running"}} ({{printf "%.0f" .Percent}}%){{end}}
+        {{if .Error}}- {{.Error}}{{end}}
+        <a href="/jobs/{{.Id}}">details</a>
       </li>
-      {{else}}
-      <li>No videos uploaded yet.</li>
       {{end}}
     </ul>
+    {{end}}
+    <h2>Watchlist</h2>
+    <form action="/" method="get">
+      <input type="search" name="q" value="{{.Query.Text}}" placeholder="Search videos" />
+      <select name="sort">
+        <option value="uploaded_at" {{if eq .Query.SortBy "uploaded_at"}}selected{{end}}>Upload time</option>
+        <option value="title" {{if eq .Query.SortBy "title"}}selected{{end}}>Title</option>
+        <option value="duration" {{if eq .Query.SortBy "duration"}}selected{{end}}>Duration</option>
+      </select>
+      <select name="order">
+        <option value="desc">Descending</option>
+        <option value="asc" {{if .Query.Ascending}}selected{{end}}>Ascending</option>
+      </select>
+      <input type="submit" value="Search" />
+    </form>
+    <div style="display: grid; grid-template-columns: repeat(auto-fill, minmax(240px, 1fr)); gap: 16px">
+      {{range .Videos}}
+      <div>
+        <a href="/videos/{{.Id}}">
+          <img src="/content/{{.Id}}/poster.jpg" alt="" loading="lazy"
+            style="width: 100%; aspect-ratio: 16 / 9; object-fit: cover; background: #ddd"
+            onerror="this.removeAttribute('src')" />
+          <div>{{or .Title .Id}}</div>
+        </a>
+        <small>{{duration .Duration}}{{if .Height}} &middot; {{.Height}}p{{end}} &middot; {{.UploadedAt.Format "2006-01-02 15:04"}}</small>
+      </div>
+      {{else}}
+      <p>{{if .Query.Text}}No videos match your search.{{else}}No videos uploaded yet.{{end}}</p>
+      {{end}}
+    </div>
+    <p>
+      {{if .PrevURL}}<a href="{{.PrevURL}}">&laquo; Previous</a>{{end}}
+      {{if .NextURL}}<a href="{{.NextURL}}">Next &raquo;</a>{{end}}
+    </p>
   </body>
 </html>
 `
@@ -35,18 +240,108 @@ const videoHTML = `
 <html>
   <head>
     <meta charset="UTF-8" />
-    <title>{{.Id}} - TritonTube</title>
+    <title>{{or .Title .Id}} - TritonTube</title>
     <script src="https://cdn.dashjs.org/latest/dash.all.min.js"></script>
   </head>
   <body>
-    <h1>{{.Id}}</h1>
+    <h1>{{or .Title .Id}}</h1>
 	  <p>Uploaded at: {{.UploadedAt}}</p>
+    {{if .Description}}<p>{{.Description}}</p>{{end}}
+
+    <video id="player" controls poster="/content/{{.Id}}/poster.jpg" style="width: 640px; height: 360px"></video>
+    <p id="qualityPicker">
+      <label for="quality">Quality:</label>
+      <select id="quality">
+        <option value="auto">Auto</option>
+      </select>
+    </p>
+    <script>
+      var video = document.querySelector("#player");
+      var hlsUrl = "/content/{{.Id}}/master.m3u8";
+      var dashUrl = "/content/{{.Id}}/manifest.mpd";
+
+      // Browsers without Media Source Extensions, such as Safari on iOS, cannot
+      // run dash.js but play HLS natively.
+      var hasMSE = window.MediaSource || window.ManagedMediaSource;
+      if (!hasMSE && video.canPlayType("application/vnd.apple.mpegurl")) {
+        // Native HLS picks the rendition itself.
+        document.querySelector("#qualityPicker").style.display = "none";
+        video.src = hlsUrl;
+      } else {
+        startDash();
+      }
+
+      function startDash() {
+        var player = dashjs.MediaPlayer().create();
+        player.initialize(video, dashUrl, false);
+
+        // dash.js 5 replaced the bitrate info API with representations.
+        function videoQualities() {
+          if (player.getRepresentationsByType) {
+            return player.getRepresentationsByType("video");
+          }
+          return player.getBitrateInfoListFor("video");
+        }
+
+        function setAutoSwitch(enabled) {
+          player.updateSettings({
+            streaming: { abr: { autoSwitchBitrate: { video: enabled } } },
+          });
+        }
+
+        var quality = document.querySelector("#quality");
+        player.on(dashjs.MediaPlayer.events.STREAM_INITIALIZED, function () {
+          videoQualities().forEach(function (q, i) {
+            var kbps = Math.round((q.bandwidth || q.bitrate) / 1000);
+            var option = document.createElement("option");
+            option.value = i;
+            option.textContent = q.height + "p (" + kbps + " kbps)";
+            quality.appendChild(option);
+          });
+        });
+        quality.addEventListener("change", function () {
+          if (quality.value === "auto") {
+            setAutoSwitch(true);
+            return;
+          }
+          setAutoSwitch(false);
+          var index = parseInt(quality.value, 10);
+          if (player.setRepresentationForTypeByIndex) {
+            player.setRepresentationForTypeByIndex("video", index);
+          } else {
+            player.setQualityFor("video", index);
+          }
+        });
+      }
+    </script>
+
+    <h2>Details</h2>
+    <table>
+      <tr><th align="left">Duration</th><td>{{duration .Duration}}</td></tr>
+      <tr><th align="left">Resolution</th><td>{{.Width}}x{{.Height}}</td></tr>
+      <tr><th align="left">Frame rate</th><td>{{printf "%.2f" .FrameRate}} fps</td></tr>
+      <tr><th align="left">Codecs</th><td>{{.VideoCodec}}{{if .AudioCodec}}, {{.AudioCodec}}{{end}}</td></tr>
+      <tr><th align="left">Original file</th><td>{{.OriginalFilename}} ({{fileSize .OriginalSize}})</td></tr>
+    </table>
 
-    <video id="dashPlayer" controls style="width: 640px; height: 360px"></video>
+    <p>
+      <button id="delete">Delete video</button>
+    </p>
     <script>
-      var url = "/content/{{.Id}}/manifest.mpd";
-      var player = dashjs.MediaPlayer().create();
-      player.initialize(document.querySelector("#dashPlayer"), url, false);
+      document.querySelector("#delete").addEventListener("click", function () {
+        if (!confirm("Delete {{.Id}}? This cannot be undone.")) {
+          return;
+        }
+        fetch("/videos/{{.Id}}", { method: "DELETE" }).then(function (resp) {
+          if (resp.ok) {
+            location.href = "/";
+          } else {
+            resp.text().then(function (msg) {
+              alert("Delete failed: " + msg);
+            });
+          }
+        });
+      });
     </script>
 
     <p><a href="/">Back to Home</a></p>
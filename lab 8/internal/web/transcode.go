@@ -0,0 +1,366 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logTailSize is how much ffmpeg output is kept for failed jobs.
+const logTailSize = 4 << 10
+
+// tailBuffer keeps the last n bytes written to it.
+type tailBuffer struct {
+	n   int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.n {
+		t.buf = t.buf[len(t.buf)-t.n:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
+
+// Rendition is one representation of the bitrate ladder.
+type Rendition struct {
+	// Height is the output height in pixels; the width keeps the aspect ratio.
+	Height int
+	// VideoBitrate is the target video bitrate in ffmpeg notation, e.g. 3000k.
+	VideoBitrate string
+}
+
+// DefaultLadder is the bitrate ladder used when none is configured.
+var DefaultLadder = []Rendition{
+	{Height: 240, VideoBitrate: "400k"},
+	{Height: 480, VideoBitrate: "1000k"},
+	{Height: 720, VideoBitrate: "3000k"},
+	{Height: 1080, VideoBitrate: "6000k"},
+}
+
+// ParseLadder parses a comma-separated list of height:bitrate pairs, such as
+// "480:1000k,720:3000k", into renditions ordered by height.
+func ParseLadder(s string) ([]Rendition, error) {
+	var ladder []Rendition
+	for _, entry := range strings.Split(s, ",") {
+		height, bitrate, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || bitrate == "" {
+			return nil, fmt.Errorf("invalid rendition %q, want height:bitrate", entry)
+		}
+		h, err := strconv.Atoi(strings.TrimSuffix(height, "p"))
+		if err != nil || h <= 0 {
+			return nil, fmt.Errorf("invalid rendition height %q", height)
+		}
+		ladder = append(ladder, Rendition{Height: h, VideoBitrate: bitrate})
+	}
+	slices.SortFunc(ladder, func(a, b Rendition) int { return a.Height - b.Height })
+	return ladder, nil
+}
+
+// selectRenditions drops the renditions taller than the source. If the source
+// is smaller than every rendition, the lowest one is kept at the source height.
+func selectRenditions(ladder []Rendition, sourceHeight int) []Rendition {
+	if sourceHeight <= 0 {
+		return ladder
+	}
+	var selected []Rendition
+	for _, r := range ladder {
+		if r.Height <= sourceHeight {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) == 0 && len(ladder) > 0 {
+		selected = []Rendition{{Height: sourceHeight, VideoBitrate: ladder[0].VideoBitrate}}
+	}
+	return selected
+}
+
+// videoProbe describes an input video as reported by ffprobe.
+type videoProbe struct {
+	Duration   float64
+	Width      int
+	Height     int
+	VideoCodec string
+	AudioCodec string
+	FrameRate  float64
+	HasAudio   bool
+}
+
+// probeVideo inspects the video at path with ffprobe.
+func probeVideo(path string) (*videoProbe, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration:stream=codec_type,codec_name,width,height,avg_frame_rate",
+		"-of", "json",
+		path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	var result struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType    string `json:"codec_type"`
+			CodecName    string `json:"codec_name"`
+			Width        int    `json:"width"`
+			Height       int    `json:"height"`
+			AvgFrameRate string `json:"avg_frame_rate"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("parse ffprobe output failed: %v", err)
+	}
+
+	probe := &videoProbe{}
+	probe.Duration, _ = strconv.ParseFloat(result.Format.Duration, 64)
+	for _, stream := range result.Streams {
+		switch stream.CodecType {
+		case "video":
+			if probe.VideoCodec == "" {
+				probe.VideoCodec = stream.CodecName
+				probe.Width = stream.Width
+				probe.Height = stream.Height
+				probe.FrameRate = parseFrameRate(stream.AvgFrameRate)
+			}
+		case "audio":
+			if !probe.HasAudio {
+				probe.AudioCodec = stream.CodecName
+				probe.HasAudio = true
+			}
+		}
+	}
+	return probe, nil
+}
+
+// parseFrameRate parses a frame rate reported by ffprobe as a fraction, such
+// as 30000/1001. It returns 0 if the rate is unknown.
+func parseFrameRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// dashArgs returns the ffmpeg arguments that transcode input into one DASH
+// manifest with a video representation per rendition and a single audio one.
+// An HLS master playlist, master.m3u8, and one media playlist per
+// representation are written next to it and share the same fMP4 segments.
+func dashArgs(input string, manifestPath string, renditions []Rendition, hasAudio bool) []string {
+	args := []string{
+		"-nostats",            // no interactive stats on stderr
+		"-progress", "pipe:1", // machine-readable progress on stdout
+		"-i", input, // input file
+	}
+	for range renditions {
+		args = append(args, "-map", "0:v:0")
+	}
+	if hasAudio {
+		args = append(args, "-map", "0:a:0")
+	}
+	args = append(args,
+		"-c:v", "libx264", // video codec
+		"-c:a", "aac", // audio codec
+		"-bf", "1", // max 1 b-frame
+		"-keyint_min", "120", // minimum keyframe interval
+		"-g", "120", // keyframe every 120 frames
+		"-sc_threshold", "0", // scene change threshold
+	)
+	for i, r := range renditions {
+		args = append(args,
+			fmt.Sprintf("-filter:v:%d", i), fmt.Sprintf("scale=-2:%d", r.Height), // output height
+			fmt.Sprintf("-b:v:%d", i), r.VideoBitrate, // video bitrate
+		)
+	}
+	adaptationSets := "id=0,streams=v"
+	if hasAudio {
+		adaptationSets += " id=1,streams=a"
+	}
+	args = append(args,
+		"-b:a", "128k", // audio bitrate
+		"-f", "dash", // dash format
+		"-adaptation_sets", adaptationSets, // one adaptation set per media type
+		"-use_timeline", "1", // use timeline
+		"-use_template", "1", // use template
+		"-init_seg_name", "init-$RepresentationID$.m4s", // init segment naming
+		"-media_seg_name", "chunk-$RepresentationID$-$Number%05d$.m4s", // media segment naming
+		"-seg_duration", "4", // segment duration in seconds
+		"-hls_playlist", "1", // also write HLS playlists
+		manifestPath, // output file
+	)
+	return args
+}
+
+// posterFilename is the name under which a video's poster image is stored.
+const posterFilename = "poster.jpg"
+
+// extractPoster writes the frame at offset into the video at input to output
+// as a JPEG.
+func extractPoster(input string, output string, offset time.Duration) error {
+	cmd := exec.Command("ffmpeg",
+		"-v", "error", // only report errors
+		"-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64), // seek before decoding
+		"-i", input, // input file
+		"-frames:v", "1", // a single frame
+		"-q:v", "3", // JPEG quality
+		output, // output file
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// storeOutput streams the transcoded file at path into the content service.
+func (s *server) storeOutput(contentId string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read segment file: %v", err)
+	}
+	defer f.Close()
+
+	w, err := createContent(s.contentService, contentId, filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to write segment file: %v", err)
+	}
+	_, err = io.Copy(w, f)
+	if err := closeContent(w, err); err != nil {
+		return fmt.Errorf("failed to write segment file: %v", err)
+	}
+	return nil
+}
+
+// transcode converts the uploaded file of job to DASH and HLS, extracts its
+// poster image, stores the output through the content service and records the
+// video's metadata. An upload identical to an earlier one reuses its output.
+func (s *server) transcode(job *transcodeJob) error {
+	defer os.Remove(job.inputPath)
+
+	shared, err := s.claimContent(job)
+	if shared || err != nil {
+		return err
+	}
+	defer s.releaseContent(job.meta.ContentHash)
+
+	if err := checkWritable(s.contentService); err != nil {
+		return err
+	}
+
+	// The content is stored under an ID of its own, which only this job's
+	// video references at first.
+	contentId := job.VideoId + "-" + job.Id
+
+	outDir, err := os.MkdirTemp("", "tritontube-"+job.VideoId+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	probe, err := probeVideo(job.inputPath)
+	if err != nil {
+		return err
+	}
+	duration := probe.Duration
+	renditions := selectRenditions(s.ladder, probe.Height)
+
+	// A missing poster only affects how the video is listed.
+	posterOffset := s.posterOffset
+	if duration > 0 && posterOffset.Seconds() >= duration {
+		posterOffset = time.Duration(duration / 2 * float64(time.Second))
+	}
+	if err := extractPoster(job.inputPath, filepath.Join(outDir, posterFilename), posterOffset); err != nil {
+		log.Printf("Extract poster of %v failed: %v", job.VideoId, err)
+	}
+
+	manifestPath := filepath.Join(outDir, "manifest.mpd")
+
+	cmd := exec.Command("ffmpeg", dashArgs(job.inputPath, manifestPath, renditions, probe.HasAudio)...)
+	logTail := &tailBuffer{n: logTailSize}
+	cmd.Stderr = logTail
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %v", err)
+	}
+
+	// Progress is reported as key=value lines; out_time_us is the position
+	// reached in the input, in microseconds.
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if !ok || duration <= 0 {
+			continue
+		}
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		percent := min(float64(us)/1e6/duration*100, 99)
+		s.jobs.update(job.Id, func(j *transcodeJob) { j.Percent = percent })
+	}
+
+	if err := cmd.Wait(); err != nil {
+		log.Println("ffmpeg output: ", logTail.String())
+		s.jobs.update(job.Id, func(j *transcodeJob) { j.LogTail = logTail.String() })
+		return fmt.Errorf("ffmpeg conversion failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return fmt.Errorf("failed to read out dir: %v", err)
+	}
+	for _, ent := range entries {
+		if ent.IsDir() {
+			continue
+		}
+		if err := s.storeOutput(contentId, filepath.Join(outDir, ent.Name())); err != nil {
+			// Files stored so far are referenced by no video.
+			if err := s.contentService.Delete(contentId); err != nil {
+				log.Printf("Delete content of %v failed: %v", job.VideoId, err)
+			}
+			return err
+		}
+	}
+
+	meta := job.meta
+	meta.Id = job.VideoId
+	meta.UploadedAt = job.CreatedAt
+	meta.Duration = time.Duration(probe.Duration * float64(time.Second))
+	meta.Width = probe.Width
+	meta.Height = probe.Height
+	meta.VideoCodec = probe.VideoCodec
+	meta.AudioCodec = probe.AudioCodec
+	meta.FrameRate = probe.FrameRate
+	meta.ContentId = contentId
+	if err := s.metadataService.Create(meta); err != nil {
+		if err := s.contentService.Delete(contentId); err != nil {
+			log.Printf("Delete content of %v failed: %v", job.VideoId, err)
+		}
+		return fmt.Errorf("failed to write metadata: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,479 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resumable uploads follow the tus protocol (https://tus.io/protocols/resumable-upload),
+// with the creation, termination and expiration extensions. A client creates
+// an upload with POST /uploads/, sends the file in any number of PATCH
+// requests at the current offset, and after an interruption asks for the
+// offset with HEAD before continuing. The upload is queued for transcoding
+// when its last byte arrives.
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	uploadsPath   = "/uploads/"
+
+	// maxUploadSize is the largest upload accepted, resumable or not.
+	maxUploadSize = 10 << 30
+	// uploadExpiry is how long an unfinished upload is kept after it was
+	// created.
+	uploadExpiry = 24 * time.Hour
+)
+
+// tusUpload is the state of a resumable upload, stored as JSON next to the
+// received data. The offset is the size of the data file.
+type tusUpload struct {
+	Id          string    `json:"id"`
+	Length      int64     `json:"length"`
+	Filename    string    `json:"filename"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// JobId is set once the upload is complete and queued for transcoding.
+	JobId string `json:"job_id,omitempty"`
+}
+
+func (u *tusUpload) expiresAt() time.Time {
+	return u.CreatedAt.Add(uploadExpiry)
+}
+
+// uploadStore keeps resumable uploads in a staging directory. Each upload
+// has an info file and a data file, and is used by one request at a time.
+type uploadStore struct {
+	dir string
+
+	mutex sync.Mutex
+	busy  map[string]bool
+}
+
+func newUploadStore(dir string) *uploadStore {
+	return &uploadStore{dir: dir, busy: make(map[string]bool)}
+}
+
+func (st *uploadStore) infoPath(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+func (st *uploadStore) dataPath(id string) string {
+	return filepath.Join(st.dir, id+".part")
+}
+
+// inputPath is where the data of a complete upload is moved for transcoding,
+// keeping the extension of the uploaded file.
+func (st *uploadStore) inputPath(u *tusUpload) string {
+	return filepath.Join(st.dir, u.Id+".input"+filepath.Ext(u.Filename))
+}
+
+// acquire reserves the upload id for the caller until release, and returns
+// false if another request holds it.
+func (st *uploadStore) acquire(id string) bool {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if st.busy[id] {
+		return false
+	}
+	st.busy[id] = true
+	return true
+}
+
+func (st *uploadStore) release(id string) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	delete(st.busy, id)
+}
+
+// create stores a new upload with no data.
+func (st *uploadStore) create(u *tusUpload) error {
+	if err := os.MkdirAll(st.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create upload directory: %v", err)
+	}
+	f, err := os.OpenFile(st.dataPath(u.Id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create upload file: %v", err)
+	}
+	f.Close()
+	if err := st.save(u); err != nil {
+		os.Remove(st.dataPath(u.Id))
+		return err
+	}
+	return nil
+}
+
+func (st *uploadStore) save(u *tusUpload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload info: %v", err)
+	}
+	// Write then rename so that a crash never leaves a truncated info file.
+	tmp := st.infoPath(u.Id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write upload info: %v", err)
+	}
+	if err := os.Rename(tmp, st.infoPath(u.Id)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write upload info: %v", err)
+	}
+	return nil
+}
+
+// get returns the upload with the given id, or nil if there is none or it has
+// expired.
+func (st *uploadStore) get(id string) (*tusUpload, error) {
+	data, err := os.ReadFile(st.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read upload info: %v", err)
+	}
+	var u tusUpload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, fmt.Errorf("failed to decode upload info: %v", err)
+	}
+	if time.Now().After(u.expiresAt()) {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+// offset returns the number of bytes received for u.
+func (st *uploadStore) offset(u *tusUpload) (int64, error) {
+	if u.JobId != "" {
+		return u.Length, nil
+	}
+	info, err := os.Stat(st.dataPath(u.Id))
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat upload file: %v", err)
+	}
+	return info.Size(), nil
+}
+
+// remove deletes the upload with the given id. The data of a complete upload
+// belongs to its transcoding job and is left alone.
+func (st *uploadStore) remove(id string) {
+	os.Remove(st.dataPath(id))
+	os.Remove(st.infoPath(id))
+}
+
+// prune removes the uploads that have expired and are not in use.
+func (st *uploadStore) prune() {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return
+	}
+	for _, ent := range entries {
+		id, ok := strings.CutSuffix(ent.Name(), ".json")
+		if !ok || !st.acquire(id) {
+			continue
+		}
+		if u, err := st.get(id); err == nil && u == nil {
+			log.Println("Removing expired upload", id)
+			st.remove(id)
+		}
+		st.release(id)
+	}
+}
+
+// parseUploadMetadata parses an Upload-Metadata header, a comma-separated
+// list of keys each followed by an optional space and base64 value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %v", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// handleTus serves the tus endpoint: the collection of uploads at
+// /uploads/ and each upload at /uploads/ID.
+func (s *server) handleTus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(maxUploadSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "Unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(uploadsPath, "/")), "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.createUpload(w, r)
+		return
+	}
+	if strings.Contains(id, "/") || strings.Contains(id, ".") {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead, http.MethodPatch, http.MethodDelete:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.uploads.acquire(id) {
+		http.Error(w, "Upload is in use by another request", http.StatusLocked)
+		return
+	}
+	defer s.uploads.release(id)
+
+	u, err := s.uploads.get(id)
+	if err != nil {
+		log.Println("Read upload error: ", err)
+		http.Error(w, "Failed to read upload", http.StatusInternalServerError)
+		return
+	}
+	if u == nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		s.headUpload(w, u)
+	case http.MethodPatch:
+		s.patchUpload(w, r, u)
+	case http.MethodDelete:
+		s.uploads.remove(u.Id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// createUpload creates an upload of Upload-Length bytes. The Upload-Metadata
+// header must name the file, which determines the video ID, and may set its
+// title and description.
+func (s *server) createUpload(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "Invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length > maxUploadSize {
+		http.Error(w, "Upload exceeds Tus-Max-Size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, "Invalid Upload-Metadata: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	filename := filepath.Base(metadata["filename"])
+	if videoIdOf(filename) == "" || filename == "." || filename == string(filepath.Separator) {
+		http.Error(w, "Upload-Metadata must include a filename", http.StatusBadRequest)
+		return
+	}
+	if err := s.checkVideoIdFree(videoIdOf(filename)); err != nil {
+		code, msg := errorStatus(err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	s.uploads.prune()
+	u := &tusUpload{
+		Id:          newJobId(),
+		Length:      length,
+		Filename:    filename,
+		Title:       strings.TrimSpace(metadata["title"]),
+		Description: strings.TrimSpace(metadata["description"]),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.uploads.create(u); err != nil {
+		log.Println("Create upload error: ", err)
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Created upload %v of %v (%d bytes)", u.Id, filename, length)
+
+	w.Header().Set("Location", uploadsPath+u.Id)
+	w.Header().Set("Upload-Expires", u.expiresAt().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *server) headUpload(w http.ResponseWriter, u *tusUpload) {
+	offset, err := s.uploads.offset(u)
+	if err != nil {
+		log.Println("Read upload error: ", err)
+		http.Error(w, "Failed to read upload", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	w.Header().Set("Upload-Expires", u.expiresAt().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+// patchUpload appends the request body to u at Upload-Offset, which must be
+// the number of bytes received so far. Once all bytes have arrived the upload
+// is queued for transcoding; if the queue is full the client retries with an
+// empty PATCH at the final offset.
+func (s *server) patchUpload(w http.ResponseWriter, r *http.Request, u *tusUpload) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := s.uploads.offset(u)
+	if err != nil {
+		log.Println("Read upload error: ", err)
+		http.Error(w, "Failed to read upload", http.StatusInternalServerError)
+		return
+	}
+	clientOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	if clientOffset != offset {
+		http.Error(w, fmt.Sprintf("Upload-Offset is %d, expected %d", clientOffset, offset), http.StatusConflict)
+		return
+	}
+	if r.ContentLength > u.Length-offset {
+		http.Error(w, "Body exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if u.JobId == "" && offset < u.Length {
+		f, err := os.OpenFile(s.uploads.dataPath(u.Id), os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			log.Println("Open upload error: ", err)
+			http.Error(w, "Failed to write upload", http.StatusInternalServerError)
+			return
+		}
+		// Whatever arrives before an interruption is kept, so the client can
+		// resume from there.
+		n, err := io.Copy(f, io.LimitReader(r.Body, u.Length-offset))
+		closeErr := f.Close()
+		offset += n
+		if err != nil || closeErr != nil {
+			log.Printf("Upload %v interrupted at %d bytes: %v", u.Id, offset, errors.Join(err, closeErr))
+			http.Error(w, "Failed to receive upload", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if u.JobId == "" && offset == u.Length {
+		if err := s.completeUpload(u); err != nil {
+			code, msg := errorStatus(err)
+			if code == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "60")
+			}
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+			http.Error(w, msg, code)
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Expires", u.expiresAt().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeUpload queues the fully received upload u for transcoding and
+// records the job. An upload whose video ID was taken meanwhile is deleted;
+// on other failures it is kept so that completing it can be retried.
+func (s *server) completeUpload(u *tusUpload) error {
+	if err := s.checkVideoIdFree(videoIdOf(u.Filename)); err != nil {
+		if isConflict(err) {
+			s.uploads.remove(u.Id)
+		}
+		return err
+	}
+
+	hash, err := hashFile(s.uploads.dataPath(u.Id))
+	if err != nil {
+		return err
+	}
+	inputPath := s.uploads.inputPath(u)
+	if err := os.Rename(s.uploads.dataPath(u.Id), inputPath); err != nil {
+		return fmt.Errorf("failed to move upload file: %v", err)
+	}
+
+	meta := VideoMetadata{
+		Id:               videoIdOf(u.Filename),
+		Title:            u.Title,
+		Description:      u.Description,
+		OriginalSize:     u.Length,
+		OriginalFilename: u.Filename,
+		ContentHash:      hash,
+	}
+	job, err := s.queueTranscode(meta, inputPath)
+	if err != nil {
+		if isConflict(err) {
+			os.Remove(inputPath)
+			s.uploads.remove(u.Id)
+		} else {
+			os.Rename(inputPath, s.uploads.dataPath(u.Id))
+		}
+		return err
+	}
+
+	u.JobId = job.Id
+	if err := s.uploads.save(u); err != nil {
+		// The job owns the data now; without the info file the upload is
+		// simply gone once the client finishes.
+		log.Println("Save upload error: ", err)
+		os.Remove(s.uploads.infoPath(u.Id))
+	}
+	log.Printf("Upload %v of %v complete, queued as job %v", u.Id, u.Filename, job.Id)
+	return nil
+}
+
+// isConflict reports whether err is reported with a Conflict status.
+func isConflict(err error) bool {
+	code, _ := errorStatus(err)
+	return code == http.StatusConflict
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open upload file: %v", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to hash upload file: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,231 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTusTestServer returns a server whose transcoding jobs are sent on the
+// returned channel instead of being run.
+func newTusTestServer(t *testing.T) (*server, chan *transcodeJob) {
+	t.Helper()
+	metadata, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "videos.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	content, err := NewFSVideoContentService(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSVideoContentService: %v", err)
+	}
+	s := NewServer(metadata, content, ServerOptions{UploadDir: t.TempDir()})
+	started := make(chan *transcodeJob, 1)
+	s.jobs = newJobQueue(1, 1, func(job *transcodeJob) error {
+		started <- job
+		return nil
+	})
+	s.jobs.start()
+	return s, started
+}
+
+// tusRequest sends a tus request to s and returns the response.
+func tusRequest(s *server, method string, path string, body io.Reader, headers map[string]string) *http.Response {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	s.handleTus(rec, req)
+	return rec.Result()
+}
+
+// createTusUpload creates an upload of length bytes of the file named
+// filename and returns its path.
+func createTusUpload(t *testing.T, s *server, filename string, length int) string {
+	t.Helper()
+	resp := tusRequest(s, http.MethodPost, uploadsPath, nil, map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte(filename)),
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %v, want 201", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		t.Fatalf("create returned no Location")
+	}
+	return location
+}
+
+func patchTusUpload(s *server, path string, offset int, body io.Reader) *http.Response {
+	return tusRequest(s, http.MethodPatch, path, body, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.Itoa(offset),
+	})
+}
+
+// headOffset returns the offset of the upload at path.
+func headOffset(t *testing.T, s *server, path string) int {
+	t.Helper()
+	resp := tusRequest(s, http.MethodHead, path, nil, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("HEAD status = %v, want 200", resp.StatusCode)
+	}
+	offset, err := strconv.Atoi(resp.Header.Get("Upload-Offset"))
+	if err != nil {
+		t.Fatalf("HEAD Upload-Offset %q: %v", resp.Header.Get("Upload-Offset"), err)
+	}
+	return offset
+}
+
+// checkNoJob checks that no transcoding job has started.
+func checkNoJob(t *testing.T, started chan *transcodeJob) {
+	t.Helper()
+	select {
+	case job := <-started:
+		t.Fatalf("job %v started before the upload was complete", job.Id)
+	default:
+	}
+}
+
+// interruptedReader returns its data and then fails, like a request body
+// whose connection dropped.
+type interruptedReader struct {
+	data []byte
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestTusUpload(t *testing.T) {
+	s, started := newTusTestServer(t)
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	path := createTusUpload(t, s, "lecture.mp4", len(data))
+	if offset := headOffset(t, s, path); offset != 0 {
+		t.Fatalf("offset of new upload = %d, want 0", offset)
+	}
+
+	resp := patchTusUpload(s, path, 0, bytes.NewReader(data[:300]))
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Upload-Offset") != "300" {
+		t.Fatalf("PATCH = %v at offset %q, want 204 at 300", resp.StatusCode, resp.Header.Get("Upload-Offset"))
+	}
+	if offset := headOffset(t, s, path); offset != 300 {
+		t.Fatalf("offset after PATCH = %d, want 300", offset)
+	}
+	checkNoJob(t, started)
+
+	// A PATCH at another offset than the server's is rejected.
+	for _, offset := range []int{0, 200, 400} {
+		resp := patchTusUpload(s, path, offset, bytes.NewReader(data[offset:offset+100]))
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("PATCH at offset %d status = %v, want 409", offset, resp.StatusCode)
+		}
+	}
+	if offset := headOffset(t, s, path); offset != 300 {
+		t.Fatalf("offset after rejected PATCHes = %d, want 300", offset)
+	}
+
+	// An interrupted PATCH keeps what arrived, and the client resumes from
+	// the offset HEAD reports.
+	resp = patchTusUpload(s, path, 300, &interruptedReader{data[300:550]})
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("interrupted PATCH status = %v, want 500", resp.StatusCode)
+	}
+	offset := headOffset(t, s, path)
+	if offset != 550 {
+		t.Fatalf("offset after interrupted PATCH = %d, want 550", offset)
+	}
+	resp = patchTusUpload(s, path, offset, bytes.NewReader(data[offset:len(data)-1]))
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("resumed PATCH status = %v, want 204", resp.StatusCode)
+	}
+	checkNoJob(t, started)
+
+	// The final byte completes the upload and starts its job.
+	resp = patchTusUpload(s, path, len(data)-1, bytes.NewReader(data[len(data)-1:]))
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("final PATCH status = %v, want 204", resp.StatusCode)
+	}
+	var job *transcodeJob
+	select {
+	case job = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no job started after the final PATCH")
+	}
+	if job.VideoId != "lecture" {
+		t.Errorf("job video ID = %q, want lecture", job.VideoId)
+	}
+	got, err := os.ReadFile(job.inputPath)
+	if err != nil {
+		t.Fatalf("read job input: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("job input has %d bytes, want the %d uploaded", len(got), len(data))
+	}
+	sum := sha256.Sum256(data)
+	if job.meta.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("job content hash = %v, want %x", job.meta.ContentHash, sum)
+	}
+
+	// A complete upload reports its full length and starts no other job.
+	if offset := headOffset(t, s, path); offset != len(data) {
+		t.Errorf("offset of complete upload = %d, want %d", offset, len(data))
+	}
+	resp = patchTusUpload(s, path, len(data), bytes.NewReader(nil))
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("empty PATCH of complete upload status = %v, want 204", resp.StatusCode)
+	}
+	checkNoJob(t, started)
+}
+
+func TestTusCreateRejectsInvalidUploads(t *testing.T) {
+	s, _ := newTusTestServer(t)
+	filename := "filename " + base64.StdEncoding.EncodeToString([]byte("lecture.mp4"))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"no length", map[string]string{"Upload-Metadata": filename}, http.StatusBadRequest},
+		{"too large", map[string]string{"Upload-Length": strconv.Itoa(maxUploadSize + 1), "Upload-Metadata": filename}, http.StatusRequestEntityTooLarge},
+		{"no filename", map[string]string{"Upload-Length": "10"}, http.StatusBadRequest},
+		{"bad metadata", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename !!"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := tusRequest(s, http.MethodPost, uploadsPath, nil, tt.headers)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %v, want %v", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// Requests without the supported protocol version are rejected.
+	req := httptest.NewRequest(http.MethodPost, uploadsPath, nil)
+	req.Header.Set("Upload-Length", "10")
+	req.Header.Set("Upload-Metadata", filename)
+	rec := httptest.NewRecorder()
+	s.handleTus(rec, req)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("status without Tus-Resumable = %v, want 412", rec.Code)
+	}
+}